@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mattn/efm-langserver/langserver"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// runCheck checks the configuration file and reports the problems found by
+// section, then lints and formats the sample files given with it. It exits
+// with 1 when any error is found.
+func runCheck(yamlfile string, args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	languageID := fs.String("language-id", "", "language ID of the sample files (default: guessed from the extension)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: efm-langserver [flags] check [check flags] [sample file...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	code := exitOK
+	section := ""
+	for _, r := range langserver.CheckConfig(yamlfile, schema) {
+		if r.Section != section {
+			section = r.Section
+			fmt.Fprintln(w, section)
+		}
+		if r.Level == langserver.CheckError {
+			code = exitFindings
+		}
+		if r.Tool != "" {
+			fmt.Fprintf(w, "  %s: %s: %s\n", r.Tool, r.Level, r.Message)
+		} else {
+			fmt.Fprintf(w, "  %s: %s\n", r.Level, r.Message)
+		}
+	}
+	if fs.NArg() == 0 || code != exitOK {
+		return code
+	}
+
+	config, err := langserver.LoadConfig(yamlfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	// What goes wrong running the tools is logged, and reported as
+	// warnings.
+	var logs bytes.Buffer
+	config.Logger = log.New(&logs, "", 0)
+	if config.LogLevel < 1 {
+		config.LogLevel = 1
+	}
+	runner, err := langserver.NewRunner(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer runner.Close()
+
+	for _, fname := range fs.Args() {
+		fmt.Fprintf(w, "sample %s\n", fname)
+		logs.Reset()
+		fileToDiagnostics, err := runner.Lint(context.Background(), fname, *languageID)
+		if err != nil {
+			fmt.Fprintf(w, "  lint: %s: %v\n", langserver.CheckError, err)
+			code = exitFindings
+		} else {
+			n := 0
+			for _, diagnostics := range fileToDiagnostics {
+				n += len(diagnostics)
+			}
+			fmt.Fprintf(w, "  lint: %s: %d diagnostics\n", langserver.CheckOK, n)
+		}
+		_, err = runner.Format(fname, *languageID, langserver.FormattingOptions{})
+		if err != nil {
+			fmt.Fprintf(w, "  format: %s: %v\n", langserver.CheckError, err)
+			code = exitFindings
+		} else {
+			fmt.Fprintf(w, "  format: %s\n", langserver.CheckOK)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "  %s: %s\n", langserver.CheckWarning, line)
+			}
+		}
+	}
+	return code
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/efm-langserver/langserver"
+)
+
+// Exit codes of the lint and format commands.
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitError    = 2
+)
+
+// severities are the names accepted by -fail-level, indexed by the LSP
+// DiagnosticSeverity.
+var severities = []string{"none", "error", "warning", "info", "hint"}
+
+// runCommand runs a one-shot subcommand with the same configuration the
+// language server uses, and returns the exit code.
+func runCommand(config *langserver.Config, args []string) int {
+	if args[0] == "preset" {
+		return runPreset(args[1:], os.Stdout)
+	}
+
+	runner, err := langserver.NewRunner(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer runner.Close()
+
+	switch args[0] {
+	case "lint":
+		return runLint(runner, args[1:], os.Stdout)
+	case "format":
+		return runFormat(runner, args[1:], os.Stdout)
+	case "explain":
+		return runExplain(runner, args[1:], os.Stdout)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command: %v\n", args[0])
+	return exitError
+}
+
+type fileDiagnostic struct {
+	File string `json:"file"`
+	langserver.Diagnostic
+}
+
+func runLint(runner *langserver.Runner, args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	languageID := fs.String("language-id", "", "language ID of the files (default: guessed from the extension)")
+	outputFormat := fs.String("output-format", "text", "output format: text, json, sarif or github")
+	failLevel := fs.String("fail-level", "error", "exit with 1 for diagnostics of this severity or worse: error, warning, info, hint or none")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: efm-langserver [flags] lint [lint flags] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	threshold := -1
+	for i, name := range severities {
+		if name == *failLevel {
+			threshold = i
+		}
+	}
+	if threshold < 0 {
+		fmt.Fprintf(os.Stderr, "invalid fail-level: %v\n", *failLevel)
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	code := exitOK
+	var diagnostics []fileDiagnostic
+	for _, fname := range fs.Args() {
+		fileToDiagnostics, err := runner.Lint(context.Background(), fname, *languageID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+			continue
+		}
+		for diagFname, ds := range fileToDiagnostics {
+			for _, d := range ds {
+				diagnostics = append(diagnostics, fileDiagnostic{File: relativePath(diagFname), Diagnostic: d})
+			}
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line < b.Range.Start.Line
+		}
+		return a.Range.Start.Character < b.Range.Start.Character
+	})
+
+	var err error
+	switch *outputFormat {
+	case "text":
+		err = writeText(w, diagnostics)
+	case "json":
+		err = writeJSON(w, diagnostics)
+	case "sarif":
+		err = writeSARIF(w, diagnostics)
+	case "github":
+		err = writeGitHub(w, diagnostics)
+	default:
+		err = fmt.Errorf("invalid output-format: %v", *outputFormat)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if code == exitOK {
+		for _, d := range diagnostics {
+			if severity(d.Diagnostic) <= threshold {
+				code = exitFindings
+				break
+			}
+		}
+	}
+	return code
+}
+
+// runExplain prints which tools apply to the files, and how they are run.
+func runExplain(runner *langserver.Runner, args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	languageID := fs.String("language-id", "", "language ID of the files (default: guessed from the extension)")
+	outputFormat := fs.String("output-format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: efm-langserver [flags] explain [explain flags] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 || (*outputFormat != "text" && *outputFormat != "json") {
+		fs.Usage()
+		return exitError
+	}
+
+	code := exitOK
+	explanations := []*langserver.Explanation{}
+	for _, fname := range fs.Args() {
+		e, err := runner.Explain(fname, *languageID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+			continue
+		}
+		explanations = append(explanations, e)
+	}
+	if *outputFormat == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(explanations); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return code
+	}
+	for _, e := range explanations {
+		writeExplanation(w, e)
+	}
+	return code
+}
+
+func writeExplanation(w io.Writer, e *langserver.Explanation) {
+	fmt.Fprintf(w, "%s (%s)\n", e.URI, e.LanguageID)
+	if len(e.Tools) == 0 {
+		fmt.Fprintln(w, "  no tools are configured")
+	}
+	for _, t := range e.Tools {
+		status := "runs"
+		if !t.Runs {
+			status = "skipped"
+		}
+		fmt.Fprintf(w, "  %s: %s [%s]: %s\n", t.Capability, t.Tool, t.Language, status)
+		if t.Source != "" {
+			fmt.Fprintf(w, "    configured in: %s\n", t.Source)
+		}
+		for _, reason := range t.Skipped {
+			fmt.Fprintf(w, "    skipped: %s\n", reason)
+		}
+		fmt.Fprintf(w, "    root: %s (%s)\n", t.RootPath, t.RootReason)
+		fmt.Fprintf(w, "    command: %s\n", t.Command)
+		if t.Argv != nil {
+			fmt.Fprintf(w, "    argv: %q\n", t.Argv)
+		}
+		if t.Stdin {
+			fmt.Fprintln(w, "    stdin: the document")
+		}
+		for _, env := range t.Env {
+			fmt.Fprintf(w, "    env: %s\n", env)
+		}
+	}
+}
+
+// runPreset prints the configuration of the presets given, or lists the
+// presets when none is.
+func runPreset(args []string, w io.Writer) int {
+	if len(args) == 0 {
+		for _, name := range langserver.PresetNames() {
+			fmt.Fprintln(w, name)
+		}
+		return exitOK
+	}
+	code := exitOK
+	for _, name := range args {
+		b, err := langserver.PresetConfig(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+			continue
+		}
+		w.Write(b)
+	}
+	return code
+}
+
+// severity returns the severity of d, which is an error if not given.
+func severity(d langserver.Diagnostic) int {
+	if d.Severity < 1 || d.Severity >= len(severities) {
+		return 1
+	}
+	return d.Severity
+}
+
+func relativePath(fname string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return fname
+	}
+	if rel, err := filepath.Rel(wd, fname); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return fname
+}
+
+func writeText(w io.Writer, diagnostics []fileDiagnostic) error {
+	for _, d := range diagnostics {
+		message := d.Message
+		var tags []string
+		if d.Source != nil {
+			tags = append(tags, *d.Source)
+		}
+		if d.Code != nil {
+			tags = append(tags, *d.Code)
+		}
+		if len(tags) > 0 {
+			message += " [" + strings.Join(tags, " ") + "]"
+		}
+		_, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", d.File, d.Range.Start.Line+1, d.Range.Start.Character+1, severities[severity(d.Diagnostic)], message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, diagnostics []fileDiagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []fileDiagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diagnostics)
+}
+
+func writeSARIF(w io.Writer, diagnostics []fileDiagnostic) error {
+	type region struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region region `json:"region"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID  string `json:"ruleId,omitempty"`
+		Level   string `json:"level"`
+		Message struct {
+			Text string `json:"text"`
+		} `json:"message"`
+		Locations []location `json:"locations"`
+	}
+
+	results := []result{}
+	for _, d := range diagnostics {
+		var r result
+		if d.Code != nil {
+			r.RuleID = *d.Code
+		}
+		switch severity(d.Diagnostic) {
+		case 1:
+			r.Level = "error"
+		case 2:
+			r.Level = "warning"
+		default:
+			r.Level = "note"
+		}
+		r.Message.Text = d.Message
+		if d.Source != nil {
+			r.Message.Text = *d.Source + ": " + d.Message
+		}
+		var l location
+		l.PhysicalLocation.ArtifactLocation.URI = d.File
+		l.PhysicalLocation.Region = region{
+			StartLine:   d.Range.Start.Line + 1,
+			StartColumn: d.Range.Start.Character + 1,
+			EndLine:     d.Range.End.Line + 1,
+			EndColumn:   d.Range.End.Character + 1,
+		}
+		r.Locations = []location{l}
+		results = append(results, r)
+	}
+
+	sarif := map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []any{
+			map[string]any{
+				"tool": map[string]any{
+					"driver": map[string]any{
+						"name":           name,
+						"version":        version,
+						"informationUri": "https://github.com/mattn/efm-langserver",
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarif)
+}
+
+// writeGitHub writes workflow commands for GitHub Actions, which show up as
+// annotations on pull requests.
+func writeGitHub(w io.Writer, diagnostics []fileDiagnostic) error {
+	data := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	property := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	for _, d := range diagnostics {
+		command := "error"
+		switch severity(d.Diagnostic) {
+		case 2:
+			command = "warning"
+		case 3, 4:
+			command = "notice"
+		}
+		params := fmt.Sprintf("file=%s,line=%d,col=%d,endLine=%d,endColumn=%d",
+			property.Replace(d.File), d.Range.Start.Line+1, d.Range.Start.Character+1, d.Range.End.Line+1, d.Range.End.Character+1)
+		if d.Source != nil {
+			params += ",title=" + property.Replace(*d.Source)
+		}
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", command, params, data.Replace(d.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runFormat(runner *langserver.Runner, args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("format", flag.ContinueOnError)
+	languageID := fs.String("language-id", "", "language ID of the files (default: guessed from the extension)")
+	write := fs.Bool("write", false, "write the result to the files instead of stdout")
+	check := fs.Bool("check", false, "list the files that are not formatted and exit with 1 if any")
+	tabSize := fs.Int("tab-size", 0, "tabSize formatting option")
+	useTabs := fs.Bool("use-tabs", false, "set the insertSpaces formatting option to false")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: efm-langserver [flags] format [format flags] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	options := langserver.FormattingOptions{}
+	if *tabSize > 0 {
+		options["tabSize"] = *tabSize
+		options["insertSpaces"] = !*useTabs
+	} else if *useTabs {
+		options["insertSpaces"] = false
+	}
+
+	code := exitOK
+	for _, fname := range fs.Args() {
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+			continue
+		}
+		formatted, err := runner.Format(fname, *languageID, options)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+			continue
+		}
+		changed := formatted != string(b)
+		switch {
+		case *check:
+			if changed {
+				fmt.Fprintln(w, fname)
+				if code == exitOK {
+					code = exitFindings
+				}
+			}
+		case *write:
+			if !changed {
+				continue
+			}
+			fi, err := os.Stat(fname)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = exitError
+				continue
+			}
+			if err := os.WriteFile(fname, []byte(formatted), fi.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = exitError
+			}
+		default:
+			io.WriteString(w, formatted)
+		}
+	}
+	return code
+}
@@ -0,0 +1,300 @@
+package langserver
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// argvCommand is a command of a Language that can be configured either as a
+// string run with the shell, or as an argv list run directly.
+type argvCommand struct {
+	yamlKey string
+	jsonKey string
+	command *string
+	argv    *[]string
+}
+
+func (l *Language) argvCommands() []argvCommand {
+	return []argvCommand{
+		{"lint-command", "lintCommand", &l.LintCommand, &l.LintArgv},
+		{"lint-fix-command", "lintFixCommand", &l.LintFixCommand, &l.LintFixArgv},
+		{"format-command", "formatCommand", &l.FormatCommand, &l.FormatArgv},
+		{"symbol-command", "symbolCommand", &l.SymbolCommand, &l.SymbolArgv},
+		{"completion-command", "completionCommand", &l.CompletionCommand, &l.CompletionArgv},
+		{"hover-command", "hoverCommand", &l.HoverCommand, &l.HoverArgv},
+	}
+}
+
+// setArgv sets the argv list of commands, and their string form to the
+// joined argv which is used to name the command and in logs. An argv list
+// decoded before is dropped once its command is set as a string.
+func setArgv(commands []argvCommand, argv map[string][]string) {
+	for _, c := range commands {
+		if list, ok := argv[c.yamlKey]; ok {
+			*c.argv = list
+			*c.command = strings.Join(list, " ")
+		} else if *c.command != strings.Join(*c.argv, " ") {
+			*c.argv = nil
+		}
+	}
+}
+
+// UnmarshalYAML is
+func (l *Language) UnmarshalYAML(node *yaml.Node) error {
+	type plain Language
+	commands := l.argvCommands()
+	keys := make(map[string]bool, len(commands))
+	for _, c := range commands {
+		keys[c.yamlKey] = true
+	}
+	l.node = node
+	node, argv, err := splitArgv(node, keys)
+	if err != nil {
+		return err
+	}
+	if err := node.Decode((*plain)(l)); err != nil {
+		return err
+	}
+	setArgv(l.argvCommands(), argv)
+	return nil
+}
+
+// MarshalYAML is
+func (l Language) MarshalYAML() (any, error) {
+	type plain Language
+	var node yaml.Node
+	if err := node.Encode((*plain)(&l)); err != nil {
+		return nil, err
+	}
+	for _, c := range l.argvCommands() {
+		if len(*c.argv) > 0 {
+			if err := setMappingValue(&node, c.yamlKey, *c.argv); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &node, nil
+}
+
+// UnmarshalJSON is
+func (l *Language) UnmarshalJSON(b []byte) error {
+	type plain Language
+	commands := l.argvCommands()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	l.raw = append([]byte(nil), b...)
+	argv := map[string][]string{}
+	for _, c := range commands {
+		if raw, ok := fields[c.jsonKey]; ok && strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var list []string
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return err
+			}
+			argv[c.yamlKey] = list
+			delete(fields, c.jsonKey)
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, (*plain)(l)); err != nil {
+		return err
+	}
+	setArgv(l.argvCommands(), argv)
+	return nil
+}
+
+// UnmarshalJSON is
+func (c *Command) UnmarshalJSON(b []byte) error {
+	type plain Command
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	var argv []string
+	if raw, ok := fields["command"]; ok && strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		if err := json.Unmarshal(raw, &argv); err != nil {
+			return err
+		}
+		delete(fields, "command")
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, (*plain)(c)); err != nil {
+		return err
+	}
+	if argv != nil {
+		c.Argv = argv
+		c.Command = strings.Join(argv, " ")
+	}
+	return nil
+}
+
+// UnmarshalYAML is
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	type plain Command
+	node, argv, err := splitArgv(node, map[string]bool{"command": true})
+	if err != nil {
+		return err
+	}
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if list, ok := argv["command"]; ok {
+		c.Argv = list
+		c.Command = strings.Join(list, " ")
+	}
+	return nil
+}
+
+// MarshalYAML is
+func (c Command) MarshalYAML() (any, error) {
+	type plain Command
+	var node yaml.Node
+	if err := node.Encode((*plain)(&c)); err != nil {
+		return nil, err
+	}
+	if len(c.Argv) > 0 {
+		if err := setMappingValue(&node, "command", c.Argv); err != nil {
+			return nil, err
+		}
+	}
+	return &node, nil
+}
+
+// splitArgv returns a copy of the mapping node without the sequences given
+// for keys, which are returned separately. Mappings merged with "<<" are
+// searched as well, with the same precedence as the decoder.
+func splitArgv(node *yaml.Node, keys map[string]bool) (*yaml.Node, map[string][]string, error) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	argv := map[string][]string{}
+	if node.Kind != yaml.MappingNode {
+		return node, argv, nil
+	}
+	n := *node
+	n.Content = make([]*yaml.Node, 0, len(node.Content))
+	var explicit [][2]*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind != yaml.ScalarNode || key.ShortTag() != "!!merge" {
+			explicit = append(explicit, [2]*yaml.Node{key, value})
+			continue
+		}
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		stripped := make([]*yaml.Node, len(merged))
+		// Earlier mappings take precedence over later ones.
+		for j := len(merged) - 1; j >= 0; j-- {
+			s, a, err := splitArgv(merged[j], keys)
+			if err != nil {
+				return nil, nil, err
+			}
+			stripped[j] = s
+			for k, v := range a {
+				argv[k] = v
+			}
+			for k := range keys {
+				if _, ok := a[k]; !ok && hasMappingKey(s, k) {
+					delete(argv, k)
+				}
+			}
+		}
+		if value.Kind == yaml.SequenceNode {
+			v := *value
+			v.Content = stripped
+			value = &v
+		} else {
+			value = stripped[0]
+		}
+		n.Content = append(n.Content, key, value)
+	}
+	for _, kv := range explicit {
+		key, value := kv[0], kv[1]
+		if keys[key.Value] {
+			for value.Kind == yaml.AliasNode {
+				value = value.Alias
+			}
+			if value.Kind == yaml.SequenceNode {
+				var list []string
+				if err := value.Decode(&list); err != nil {
+					return nil, nil, err
+				}
+				argv[key.Value] = list
+				continue
+			}
+			delete(argv, key.Value)
+		}
+		n.Content = append(n.Content, key, value)
+	}
+	return &n, argv, nil
+}
+
+func hasMappingKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setMappingValue(node *yaml.Node, key string, v any) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Encode(v)
+		}
+	}
+	return nil
+}
+
+// withInput appends "${INPUT}" to argv unless the input is given on stdin or
+// argv already contains it, like the string form of commands.
+func withInput(argv []string, stdin bool) []string {
+	if stdin {
+		return argv
+	}
+	for _, arg := range argv {
+		if strings.Contains(arg, "${INPUT}") {
+			return argv
+		}
+	}
+	return append(append([]string{}, argv...), "${INPUT}")
+}
+
+// replaceArgvInputFilename is replaceCommandInputFilename for argv lists.
+// Arguments are not seen by a shell, so nothing needs to be escaped.
+func replaceArgvInputFilename(argv []string, fname, rootPath string) []string {
+	ext := strings.TrimPrefix(filepath.Ext(fname), ".")
+	replacer := strings.NewReplacer(
+		"${INPUT}", fname,
+		"${FILEEXT}", ext,
+		"${FILENAME}", filepath.FromSlash(fname),
+		"${ROOT}", rootPath,
+	)
+	replaced := make([]string, len(argv))
+	for i, arg := range argv {
+		replaced[i] = replacer.Replace(arg)
+	}
+	return replaced
+}
+
+// replaceArgv replaces old with new in every argument.
+func replaceArgv(argv []string, old, new string) []string {
+	replaced := make([]string, len(argv))
+	for i, arg := range argv {
+		replaced[i] = strings.Replace(arg, old, new, -1)
+	}
+	return replaced
+}
@@ -0,0 +1,139 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLanguageArgvYAML(t *testing.T) {
+	input := `
+tools:
+  shellcheck: &shellcheck
+    lint-command: [shellcheck, -f, gcc, -]
+    lint-stdin: true
+  prettier: &prettier
+    format-command: 'prettier ${INPUT}'
+languages:
+  sh:
+    - <<: *shellcheck
+      format-command: [shfmt, -i, '${tabSize}']
+    - <<: [*prettier, *shellcheck]
+      lint-command: 'shellcheck ${INPUT}'
+commands:
+  - title: open
+    command: [xdg-open, '${FILENAME}']
+`
+	var config struct {
+		Languages map[string][]Language `yaml:"languages"`
+		Commands  []Command             `yaml:"commands"`
+	}
+	if err := yaml.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatal(err)
+	}
+	sh := config.Languages["sh"]
+	if !reflect.DeepEqual(sh[0].LintArgv, []string{"shellcheck", "-f", "gcc", "-"}) || sh[0].LintCommand != "shellcheck -f gcc -" || !sh[0].LintStdin {
+		t.Fatalf("lint-command should be merged as argv but got: %q %q", sh[0].LintArgv, sh[0].LintCommand)
+	}
+	if !reflect.DeepEqual(sh[0].FormatArgv, []string{"shfmt", "-i", "${tabSize}"}) {
+		t.Fatalf("format-command should be argv but got: %q", sh[0].FormatArgv)
+	}
+	if sh[1].LintArgv != nil || sh[1].LintCommand != "shellcheck ${INPUT}" {
+		t.Fatalf("lint-command should be overridden by a string but got: %q %q", sh[1].LintArgv, sh[1].LintCommand)
+	}
+	if sh[1].FormatArgv != nil || sh[1].FormatCommand != "prettier ${INPUT}" {
+		t.Fatalf("format-command should be a string but got: %q %q", sh[1].FormatArgv, sh[1].FormatCommand)
+	}
+	if !reflect.DeepEqual(config.Commands[0].Argv, []string{"xdg-open", "${FILENAME}"}) {
+		t.Fatalf("command should be argv but got: %q", config.Commands[0].Argv)
+	}
+
+	b, err := yaml.Marshal(sh[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumped Language
+	if err := yaml.Unmarshal(b, &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dumped.LintArgv, sh[0].LintArgv) || !reflect.DeepEqual(dumped.FormatArgv, sh[0].FormatArgv) {
+		t.Fatalf("argv should survive a dump but got:\n%s", b)
+	}
+}
+
+func TestLanguageArgvJSON(t *testing.T) {
+	var config Config
+	input := `{"languages": {"sh": [{"lintCommand": ["shellcheck", "-"], "lintStdin": true, "formatCommand": "shfmt"}]}, "commands": [{"title": "open", "command": ["xdg-open", "${FILENAME}"]}]}`
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatal(err)
+	}
+	sh := (*config.Languages)["sh"][0]
+	if !reflect.DeepEqual(sh.LintArgv, []string{"shellcheck", "-"}) || !sh.LintStdin || sh.FormatCommand != "shfmt" || sh.FormatArgv != nil {
+		t.Fatalf("unexpected language: %+v", sh)
+	}
+	if !reflect.DeepEqual((*config.Commands)[0].Argv, []string{"xdg-open", "${FILENAME}"}) {
+		t.Fatalf("command should be argv but got: %+v", (*config.Commands)[0])
+	}
+}
+
+func TestLintArgvFilenameNotInterpreted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a file name with ;")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a;touch${IFS}pwned;b")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: dir,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        "echo",
+					LintArgv:           []string{"echo", "${INPUT}:2:1:msg"},
+					LintIgnoreExitCode: true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "a\nb\n",
+			},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 1 || d[uri][0].Range.Start.Line != 1 {
+		t.Fatalf("the file name should be passed as is but got: %v", d)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatal("the file name should not be run by a shell")
+	}
+}
+
+func TestExpandFormatArgv(t *testing.T) {
+	argv := []string{"prettier", "${--tab-width:tabSize}", "${--use-tabs:!insertSpaces}", "${--range-start=charStart}", "${--print-width:printWidth}", "a b.js"}
+	got, err := expandFormatArgv(argv, FormattingOptions{"tabSize": 4, "insertSpaces": true}, map[string]int{"charStart": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"prettier", "--tab-width", "4", "--range-start=10", "a b.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("argv should be %q but got: %q", want, got)
+	}
+}
@@ -0,0 +1,213 @@
+package langserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Levels of CheckResult.
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckError   = "error"
+)
+
+// CheckResult is a problem found by CheckConfig, or tells that nothing was
+// found wrong.
+type CheckResult struct {
+	// Section is the configuration file or the language the result is
+	// about.
+	Section string
+	// Tool names the tool of the language the result is about, if any.
+	Tool    string
+	Level   string
+	Message string
+}
+
+// CheckConfig checks the configuration file and the files it includes
+// against schema, the JSON schema of the configuration, and the tools it
+// configures: their formats must compile and their executables must be
+// found. The results come by file, then by language sorted by name.
+func CheckConfig(file string, schema []byte) []CheckResult {
+	var s map[string]any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return []CheckResult{{Section: file, Level: CheckError, Message: fmt.Sprintf("invalid schema: %v", err)}}
+	}
+
+	results := checkSchema(file, s)
+	config, err := LoadConfig(file)
+	if err != nil {
+		return append(results, CheckResult{Section: file, Level: CheckError, Message: err.Error()})
+	}
+	for _, included := range config.IncludedFiles {
+		results = append(results, checkSchema(included, s)...)
+	}
+
+	if config.Commands != nil {
+		for _, command := range *config.Commands {
+			if msg, level := checkCommand(command); msg != "" {
+				results = append(results, CheckResult{Section: "commands", Tool: command.Title, Level: level, Message: msg})
+			}
+		}
+	}
+
+	var languages []string
+	if config.Languages != nil {
+		for lang := range *config.Languages {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+	for _, lang := range languages {
+		for i, tool := range (*config.Languages)[lang] {
+			name := checkToolName(tool, i)
+			problems := checkTool(tool)
+			if len(problems) == 0 {
+				results = append(results, CheckResult{Section: lang, Tool: name, Level: CheckOK, Message: "ok"})
+			}
+			for _, p := range problems {
+				p.Section = lang
+				p.Tool = name
+				results = append(results, p)
+			}
+		}
+	}
+	return results
+}
+
+// checkSchema validates file against schema.
+func checkSchema(file string, schema map[string]any) []CheckResult {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return []CheckResult{{Section: file, Level: CheckError, Message: err.Error()}}
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return []CheckResult{{Section: file, Level: CheckError, Message: err.Error()}}
+	}
+	if len(node.Content) == 0 {
+		return []CheckResult{{Section: file, Level: CheckOK, Message: "empty"}}
+	}
+	problems := validateSchema(schema, &node)
+	if len(problems) == 0 {
+		return []CheckResult{{Section: file, Level: CheckOK, Message: "valid"}}
+	}
+	results := make([]CheckResult, 0, len(problems))
+	for _, p := range problems {
+		results = append(results, CheckResult{Section: file, Level: CheckError, Message: file + ":" + p})
+	}
+	return results
+}
+
+// checkToolName returns a name for the i-th tool of a language in results.
+func checkToolName(tool Language, i int) string {
+	if tool.Name != "" {
+		return tool.Name
+	}
+	for _, c := range tool.argvCommands() {
+		if exe := commandExecutable(*c.command, *c.argv); exe != "" {
+			return filepath.Base(exe)
+		}
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// checkTool returns the problems found with tool.
+func checkTool(tool Language) []CheckResult {
+	var results []CheckResult
+	add := func(level, format string, args ...any) {
+		results = append(results, CheckResult{Level: level, Message: fmt.Sprintf(format, args...)})
+	}
+
+	configured := len(tool.Commands) > 0
+	for _, c := range tool.argvCommands() {
+		if *c.command == "" {
+			continue
+		}
+		configured = true
+		if msg, level := checkExecutable(*c.command, *c.argv); msg != "" {
+			add(level, "%s: %s", c.yamlKey, msg)
+		}
+	}
+	if !configured {
+		add(CheckWarning, "no command is configured")
+	}
+
+	if tool.LintCommand != "" {
+		if _, err := newLintParser(&tool); err != nil {
+			add(CheckError, "%v", err)
+		}
+		if _, err := columnUnitEncoding(tool.LintColumnUnit); err != nil {
+			add(CheckError, "%v", err)
+		}
+	}
+	if len(tool.SymbolFormats) > 0 {
+		if _, err := newErrorformat(tool.SymbolFormats); err != nil {
+			add(CheckError, "invalid symbol-formats: %v", err)
+		}
+	}
+	for _, env := range tool.Env {
+		if !strings.Contains(env, "=") {
+			add(CheckError, "env: %q should be NAME=value", env)
+		}
+	}
+	for _, command := range tool.Commands {
+		if msg, level := checkCommand(command); msg != "" {
+			add(level, "commands: %s: %s", command.Title, msg)
+		}
+	}
+	return results
+}
+
+// checkCommand returns what is wrong with the executable of command, and
+// how much, unless command is for another OS.
+func checkCommand(command Command) (string, string) {
+	if command.OS != "" && command.OS != runtime.GOOS {
+		return "", ""
+	}
+	return checkExecutable(command.Command, command.Argv)
+}
+
+// checkExecutable returns what is wrong with the executable of the command,
+// and how much. Executables given by a relative path are looked for from
+// the root directory of the documents, which is not known here.
+func checkExecutable(command string, argv []string) (string, string) {
+	exe := commandExecutable(command, argv)
+	if exe == "" {
+		return "", ""
+	}
+	if _, err := exec.LookPath(exe); err != nil {
+		if strings.ContainsRune(exe, '/') && !filepath.IsAbs(exe) {
+			return fmt.Sprintf("%s is not found from the current directory", exe), CheckWarning
+		}
+		return fmt.Sprintf("%s is not found: install it or fix the PATH", exe), CheckError
+	}
+	return "", ""
+}
+
+// commandExecutable returns the executable run by the command, or "" when
+// it can not be told from shell syntax.
+func commandExecutable(command string, argv []string) string {
+	if len(argv) > 0 {
+		return argv[0]
+	}
+	for _, field := range strings.Fields(command) {
+		if i := strings.IndexByte(field, '='); i > 0 && !strings.ContainsAny(field[:i], "/$") {
+			// An environment variable set for the command.
+			continue
+		}
+		if strings.ContainsAny(field, "$`'\"(){}|&;<>%") {
+			return ""
+		}
+		return field
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package langserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	schema, err := os.ReadFile(filepath.Join("..", "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	config := `version: 2
+tools:
+  base: &base
+    lint-command: ` + exe + `
+    lint-bogus: true
+languages:
+  vim:
+    - <<: *base
+      lint-timeout: 5x
+  lua:
+    - lint-command: ` + exe + `
+      lint-formats: ['%f:%l:%c: %t%*[']
+    - format-command: efm-langserver-missing-formatter
+    - lint-command: ` + exe + `
+      env: [FOO]
+`
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range CheckConfig(file, schema) {
+		got = append(got, strings.Join([]string{r.Section, r.Tool, r.Level, r.Message}, "|"))
+	}
+	want := []string{
+		file + "||error|" + file + `:5:5: tools.base: unknown key "lint-bogus"`,
+		file + "||error|" + file + `:9:21: languages.vim[0].lint-timeout: "5x" is not valid`,
+		file + "||error|" + file + `:15:13: languages.lua[2].env[0]: "FOO" is not valid`,
+		file + "||error|can not read configuration: " + file + `: time: unknown unit "x" in duration "5x"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("results should be:\n%s\nbut got:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	config = strings.Replace(config, "lint-timeout: 5x", "lint-timeout: 5s", 1)
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, r := range CheckConfig(file, schema)[2:] {
+		got = append(got, strings.Join([]string{r.Section, r.Tool, r.Level, r.Message}, "|"))
+	}
+	name := filepath.Base(exe)
+	want = []string{
+		"lua|" + name + "|error|invalid error-format: [%f:%l:%c: %t%*[]",
+		"lua|efm-langserver-missing-formatter|error|format-command: efm-langserver-missing-formatter is not found: install it or fix the PATH",
+		"lua|" + name + `|error|env: "FOO" should be NAME=value`,
+		"vim|" + name + "|ok|ok",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("results should be:\n%s\nbut got:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestCommandExecutable(t *testing.T) {
+	tests := []struct {
+		command string
+		argv    []string
+		want    string
+	}{
+		{"shellcheck -f gcc -", nil, "shellcheck"},
+		{"", []string{"shfmt", "-i", "2"}, "shfmt"},
+		{"NODE_ENV=production ./node_modules/.bin/eslint", nil, "./node_modules/.bin/eslint"},
+		{"$HOME/bin/lint", nil, ""},
+		{"'my linter' ${INPUT}", nil, ""},
+	}
+	for _, test := range tests {
+		if got := commandExecutable(test.command, test.argv); got != test.want {
+			t.Errorf("commandExecutable(%q, %q) should be %q but got: %q", test.command, test.argv, test.want, got)
+		}
+	}
+}
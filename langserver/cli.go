@@ -0,0 +1,180 @@
+package langserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Runner lints and formats files with the same handler used for clients, for
+// running a configuration from the command line.
+type Runner struct {
+	h *langHandler
+}
+
+// NewRunner returns a Runner resolving relative paths and root markers from
+// the current directory.
+func NewRunner(config *Config) (*Runner, error) {
+	h := newHandler(config)
+	wd, err := os.Getwd()
+	if err != nil {
+		h.shutdown()
+		return nil, err
+	}
+	h.rootPath = wd
+	return &Runner{h: h}, nil
+}
+
+// Close stops the runner.
+func (r *Runner) Close() {
+	r.h.shutdown()
+}
+
+// Lint runs all linters configured for languageID on fname and returns the
+// diagnostics by file name. An empty languageID is guessed from the
+// extension of fname.
+func (r *Runner) Lint(ctx context.Context, fname, languageID string) (map[string][]Diagnostic, error) {
+	uri, err := r.open(fname, languageID)
+	if err != nil {
+		return nil, err
+	}
+	defer r.h.closeFile(uri)
+
+	uriToDiagnostics, err := r.h.lint(ctx, uri, eventTypeSave)
+	if err != nil {
+		return nil, err
+	}
+	fileToDiagnostics := map[string][]Diagnostic{}
+	for diagURI, diagnostics := range uriToDiagnostics {
+		if len(diagnostics) == 0 {
+			continue
+		}
+		diagFname, err := fromURI(diagURI)
+		if err != nil {
+			return nil, err
+		}
+		fileToDiagnostics[diagFname] = append(fileToDiagnostics[diagFname], diagnostics...)
+	}
+	return fileToDiagnostics, nil
+}
+
+// Format runs all formatters configured for languageID on fname and returns
+// the formatted text, which is the text of fname if nothing changed.
+func (r *Runner) Format(fname, languageID string, options FormattingOptions) (string, error) {
+	uri, err := r.open(fname, languageID)
+	if err != nil {
+		return "", err
+	}
+	defer r.h.closeFile(uri)
+
+	text := r.h.files[uri].Text
+	edits, err := r.h.rangeFormatting(uri, Range{Position{-1, -1}, Position{-1, -1}}, options)
+	if err != nil {
+		return "", err
+	}
+	return applyTextEdits(text, edits, r.h.encoding()), nil
+}
+
+// Explain explains which tools apply to fname, and how they are run. An
+// empty languageID is guessed from the extension of fname.
+func (r *Runner) Explain(fname, languageID string) (*Explanation, error) {
+	fname, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	uri := toURI(fname)
+	r.h.discoverProject(uri)
+	return r.h.explain(uri, languageID)
+}
+
+func (r *Runner) open(fname, languageID string) (DocumentURI, error) {
+	fname, err := filepath.Abs(fname)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	if languageID == "" {
+		languageID = guessLanguageID(fname)
+	}
+	uri := toURI(fname)
+	r.h.mu.Lock()
+	r.h.files[uri] = &File{LanguageID: languageID, Text: string(b)}
+	r.h.mu.Unlock()
+	r.h.discoverProject(uri)
+	return uri, nil
+}
+
+// applyTextEdits applies non-overlapping edits to text.
+func applyTextEdits(text string, edits []TextEdit, enc PositionEncodingKind) string {
+	edits = append([]TextEdit{}, edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line > b.Line || (a.Line == b.Line && a.Character > b.Character)
+	})
+	for _, edit := range edits {
+		text = applyContentChange(text, TextDocumentContentChangeEvent{
+			Range: &Range{Start: edit.Range.Start, End: edit.Range.End},
+			Text:  edit.NewText,
+		}, enc)
+	}
+	return text
+}
+
+// languageIDs maps file extensions to the language identifiers editors
+// commonly use for them.
+var languageIDs = map[string]string{
+	"bash": "sh",
+	"c":    "c",
+	"cc":   "cpp",
+	"cpp":  "cpp",
+	"css":  "css",
+	"go":   "go",
+	"h":    "c",
+	"hpp":  "cpp",
+	"html": "html",
+	"java": "java",
+	"js":   "javascript",
+	"json": "json",
+	"jsx":  "javascriptreact",
+	"lua":  "lua",
+	"md":   "markdown",
+	"php":  "php",
+	"pl":   "perl",
+	"py":   "python",
+	"rb":   "ruby",
+	"rs":   "rust",
+	"rst":  "rst",
+	"scss": "scss",
+	"sh":   "sh",
+	"sql":  "sql",
+	"tex":  "tex",
+	"toml": "toml",
+	"ts":   "typescript",
+	"tsx":  "typescriptreact",
+	"vim":  "vim",
+	"yaml": "yaml",
+	"yml":  "yaml",
+	"zsh":  "zsh",
+}
+
+// guessLanguageID guesses the language of fname from its name, falling back
+// to the extension itself.
+func guessLanguageID(fname string) string {
+	base := filepath.Base(fname)
+	switch base {
+	case "Dockerfile":
+		return "dockerfile"
+	case "Makefile", "makefile", "GNUmakefile":
+		return "make"
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(base), "."))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return ext
+}
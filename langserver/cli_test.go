@@ -0,0 +1,113 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sed")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.py")
+	if err := os.WriteFile(file, []byte("foo  \nbar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewRunner(&Config{
+		Logger:      log.New(log.Writer(), "", log.LstdFlags),
+		Commands:    &[]Command{},
+		RootMarkers: &[]string{},
+		Languages: &map[string][]Language{
+			"python": {
+				{
+					LintCommand:        `echo ` + file + `:1:4:trailing spaces`,
+					LintFormats:        []string{"%f:%l:%c:%m"},
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+					FormatCommand:      `sed -e "s/ *$//"`,
+					FormatStdin:        true,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+
+	fileToDiagnostics, err := runner.Lint(context.Background(), file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := fileToDiagnostics[file]
+	if len(d) != 1 || d[0].Message != "trailing spaces" || d[0].Range.Start.Character != 3 {
+		t.Fatalf("unexpected diagnostics: %v", fileToDiagnostics)
+	}
+
+	formatted, err := runner.Format(file, "python", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != "foo\nbar\n" {
+		t.Fatalf("formatted text should be %q but got: %q", "foo\nbar\n", formatted)
+	}
+}
+
+func TestRunnerLintWithoutFilename(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.py")
+	if err := os.WriteFile(file, []byte("foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewRunner(&Config{
+		Logger:      log.New(log.Writer(), "", log.LstdFlags),
+		Commands:    &[]Command{},
+		RootMarkers: &[]string{},
+		Languages: &map[string][]Language{
+			"python": {
+				{
+					LintCommand:        `echo 1:2:bad`,
+					LintFormats:        []string{"%l:%c:%m"},
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+
+	// Entries without a file name belong to the linted file.
+	fileToDiagnostics, err := runner.Lint(context.Background(), file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileToDiagnostics) != 1 || len(fileToDiagnostics[file]) != 1 || fileToDiagnostics[file][0].Message != "bad" {
+		t.Fatalf("unexpected diagnostics: %v", fileToDiagnostics)
+	}
+}
+
+func TestGuessLanguageID(t *testing.T) {
+	tests := map[string]string{
+		"main.py":           "python",
+		"src/index.tsx":     "typescriptreact",
+		"docker/Dockerfile": "dockerfile",
+		"README.MD":         "markdown",
+		"foo.nim":           "nim",
+	}
+	for fname, want := range tests {
+		if got := guessLanguageID(fname); got != want {
+			t.Errorf("guessLanguageID(%q) should be %q but got: %q", fname, want, got)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultOutputLimit caps the output captured from a command unless
+// command-output-limit is set.
+const defaultOutputLimit = 64 << 20
+
+var (
+	errCommandTimeout     = errors.New("timed out")
+	errCommandOutputLimit = errors.New("output limit exceeded")
+)
+
+// shellCommand is a command run through the shell on behalf of a tool.
+type shellCommand struct {
+	Command string
+	// Args are passed to the shell after Command, as positional parameters
+	// on unix.
+	Args []string
+	// Argv is run directly instead of Command when given.
+	Argv  []string
+	Dir   string
+	Env   []string
+	Stdin io.Reader
+	// Timeout falls back to the command-timeout of the handler when zero.
+	Timeout time.Duration
+	// Combined captures stderr into the output along with stdout.
+	Combined bool
+
+	// Tool names the configured command for max-parallel, which limits how
+	// many commands of the tool run at once when MaxParallel is positive.
+	Tool        string
+	MaxParallel int
+	Priority    priority
+	// URI is the document the command runs for, if any.
+	URI DocumentURI
+}
+
+// shell returns a command running command with the shell, which kills it
+// along with its children when ctx is done.
+func shell(ctx context.Context, command string, args ...string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", append([]string{"/c", command}, args...)...)
+	}
+	return killableCommand(ctx, command, args...)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and calls
+// exceeded when more are written.
+type limitedBuffer struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int
+	exceeded func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rest := b.limit - b.buf.Len(); len(p) > rest {
+		b.buf.Write(p[:rest])
+		b.exceeded()
+		// Keep draining the pipe so the command is not blocked on it while
+		// being killed.
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// runCommand runs c once the executor lets it, killing it when it runs
+// longer than its timeout or prints more than the output limit, and returns
+// what it printed to stdout and stderr. The client is told about commands
+// that were killed.
+func (h *langHandler) runCommand(ctx context.Context, c shellCommand) ([]byte, []byte, error) {
+	release, err := h.executor.acquire(ctx, &job{
+		tool:     c.Tool,
+		limit:    c.MaxParallel,
+		priority: c.Priority,
+		uri:      c.URI,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	h.mu.Lock()
+	timeout := h.commandTimeout
+	limit := h.outputLimit
+	h.mu.Unlock()
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	if limit <= 0 {
+		limit = defaultOutputLimit
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, timeout)
+		defer cancel()
+	}
+	overflow := make(chan struct{})
+	var once sync.Once
+	exceeded := func() {
+		once.Do(func() {
+			close(overflow)
+			cancel()
+		})
+	}
+	stdout := &limitedBuffer{limit: limit, exceeded: exceeded}
+	stderr := stdout
+	if !c.Combined {
+		stderr = &limitedBuffer{limit: limit, exceeded: exceeded}
+	}
+
+	var cmd *exec.Cmd
+	if len(c.Argv) > 0 {
+		cmd = killable(exec.CommandContext(runCtx, c.Argv[0], c.Argv[1:]...))
+	} else {
+		cmd = shell(runCtx, c.Command, c.Args...)
+	}
+	cmd.Dir = c.Dir
+	cmd.Env = c.Env
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children left running by a killed shell must not keep Run waiting
+	// for them to close its output.
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+
+	select {
+	case <-overflow:
+		err = fmt.Errorf("%s: %w: %d bytes", c.Command, errCommandOutputLimit, limit)
+	default:
+		if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%s: %w after %v", c.Command, errCommandTimeout, timeout)
+		}
+	}
+	if errors.Is(err, errCommandTimeout) || errors.Is(err, errCommandOutputLimit) {
+		h.logger.Println(err)
+		h.showMessage(LogWarning, err.Error())
+	}
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// output is runCommand returning stdout and stderr together.
+func (h *langHandler) output(ctx context.Context, c shellCommand) ([]byte, error) {
+	c.Combined = true
+	b, _, err := h.runCommand(ctx, c)
+	return b, err
+}
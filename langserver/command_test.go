@@ -0,0 +1,87 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRunCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	h := &langHandler{
+		logger:         log.New(log.Writer(), "", log.LstdFlags),
+		commandTimeout: time.Minute,
+	}
+
+	start := time.Now()
+	// The child of the shell must be killed as well, or its open stdout
+	// would keep the command running.
+	_, err := h.output(context.Background(), shellCommand{Command: "sleep 10 | cat", Timeout: 100 * time.Millisecond})
+	if !errors.Is(err, errCommandTimeout) {
+		t.Fatalf("error should be a timeout but got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command should be killed after its timeout but took: %v", elapsed)
+	}
+}
+
+func TestRunCommandOutputLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses yes")
+	}
+	h := &langHandler{
+		logger:      log.New(log.Writer(), "", log.LstdFlags),
+		outputLimit: 1000,
+	}
+
+	b, err := h.output(context.Background(), shellCommand{Command: "yes"})
+	if !errors.Is(err, errCommandOutputLimit) {
+		t.Fatalf("error should be an output limit but got: %v", err)
+	}
+	if len(b) != 1000 {
+		t.Fatalf("output should be cut at 1000 bytes but got: %d", len(b))
+	}
+
+	b, err = h.output(context.Background(), shellCommand{Command: "echo foo"})
+	if err != nil || string(b) != "foo\n" {
+		t.Fatalf("output should be %q but got: %q, %v", "foo\n", b, err)
+	}
+}
+
+func TestLintTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	uri := DocumentURI("file:///foo")
+	h := &langHandler{
+		logger: log.New(log.Writer(), "", log.LstdFlags),
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `sleep 10`,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+					LintTimeout:        Duration(100 * time.Millisecond),
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+			},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d[uri]) != 0 {
+		t.Fatalf("a linter that timed out should not report diagnostics but got: %v", d)
+	}
+}
@@ -1,9 +1,13 @@
 package langserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,20 +25,21 @@ func LoadConfig(yamlfile string) (*Config, error) {
 	f, err := os.Open(yamlfile)
 	if err != nil {
 		log.Println("efm-langserver: no configuration file")
+		// Still named, so that it is loaded once created.
+		config.Filename = yamlfile
 		return &config, nil
 	}
 	defer f.Close()
 
 	err = yaml.NewDecoder(f).Decode(&config1)
 	if err != nil || config1.Version == 2 {
-		f, err = os.Open(yamlfile)
-		if err != nil {
+		if err := loadConfigFile(yamlfile, &config, nil, map[string]bool{}); err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		err = yaml.NewDecoder(f).Decode(&config)
-		if err != nil {
-			return nil, fmt.Errorf("can not read configuration: %v", err)
+		if config.Languages != nil {
+			if err := resolveTools(*config.Languages, config.Tools); err != nil {
+				return nil, err
+			}
 		}
 	} else {
 		config.Version = config1.Version
@@ -56,3 +61,191 @@ func LoadConfig(yamlfile string) (*Config, error) {
 	}
 	return &config, nil
 }
+
+// loadConfigFile decodes the configuration file onto config, followed by the
+// files it includes. stack are the files including file, and loaded the
+// files loaded so far, which are not included twice.
+func loadConfigFile(file string, config *Config, stack []string, loaded map[string]bool) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, config); err != nil {
+		return fmt.Errorf("can not read configuration: %s: %v", file, err)
+	}
+	setToolFile(config, file)
+	loaded[file] = true
+
+	stack = append(stack, file)
+	for _, pattern := range config.Include {
+		files, err := includedFiles(filepath.Dir(file), pattern)
+		if err != nil {
+			return fmt.Errorf("%s: can not include %s: %v", file, pattern, err)
+		}
+		for _, included := range files {
+			if slices.Contains(stack, included) {
+				return fmt.Errorf("%s: include cycle: %s", file, strings.Join(append(stack, included), " -> "))
+			}
+			if loaded[included] {
+				continue
+			}
+			var inc Config
+			if err := loadConfigFile(included, &inc, stack, loaded); err != nil {
+				return err
+			}
+			mergeIncluded(config, &inc)
+			config.IncludedFiles = append(config.IncludedFiles, included)
+			config.IncludedFiles = append(config.IncludedFiles, inc.IncludedFiles...)
+		}
+	}
+	return nil
+}
+
+// includedFiles returns the files matching the include pattern, relative to
+// dir. A pattern without wildcards must name an existing file.
+func includedFiles(dir, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		if _, err := os.Stat(pattern); err != nil {
+			return nil, err
+		}
+		return []string{filepath.Clean(pattern)}, nil
+	}
+	return filepath.Glob(pattern)
+}
+
+// setToolFile records file as where the tools of config were configured.
+func setToolFile(config *Config, file string) {
+	if config.Languages != nil {
+		for _, cfgs := range *config.Languages {
+			for i := range cfgs {
+				cfgs[i].file = file
+			}
+		}
+	}
+	for name, tool := range config.Tools {
+		tool.file = file
+		config.Tools[name] = tool
+	}
+}
+
+// mergeIncluded adds the languages, tools, commands and root markers of the
+// included configuration inc to config. The tools of config take precedence
+// over those of the same name in inc.
+func mergeIncluded(config, inc *Config) {
+	if inc.Languages != nil {
+		if config.Languages == nil {
+			config.Languages = &map[string][]Language{}
+		}
+		for lang, cfgs := range *inc.Languages {
+			(*config.Languages)[lang] = append(cfgs, (*config.Languages)[lang]...)
+		}
+	}
+	for name, tool := range inc.Tools {
+		if config.Tools == nil {
+			config.Tools = make(map[string]Language)
+		}
+		if _, ok := config.Tools[name]; !ok {
+			config.Tools[name] = tool
+		}
+	}
+	if inc.Commands != nil {
+		if config.Commands == nil {
+			config.Commands = &[]Command{}
+		}
+		*config.Commands = append(*inc.Commands, *config.Commands...)
+	}
+	if inc.RootMarkers != nil {
+		if config.RootMarkers == nil {
+			config.RootMarkers = &[]string{}
+		}
+		*config.RootMarkers = append(*inc.RootMarkers, *config.RootMarkers...)
+	}
+}
+
+// resolveTools replaces the tools of languages which are based on a tool of
+// tools or on a preset with the named tool, overridden by their own
+// settings.
+func resolveTools(languages map[string][]Language, tools map[string]Language) error {
+	for lang, cfgs := range languages {
+		for i := range cfgs {
+			if cfgs[i].Tool == "" && cfgs[i].Preset == "" {
+				continue
+			}
+			tool, err := resolveTool(&cfgs[i], tools, fmt.Sprintf("languages.%s[%d]", lang, i), nil)
+			if err != nil {
+				return err
+			}
+			cfgs[i] = tool
+		}
+	}
+	return nil
+}
+
+// resolveTool returns the tool l, decoded onto the tool it is based on.
+// where is the place of l in the configuration, used in errors when the
+// line of l is unknown, and seen are the names of the tools based on l.
+func resolveTool(l *Language, tools map[string]Language, where string, seen []string) (Language, error) {
+	if l.node != nil {
+		where = fmt.Sprintf("%s:%d:%d", l.file, l.node.Line, l.node.Column)
+	}
+	var resolved Language
+	switch {
+	case l.Tool != "" && l.Preset != "":
+		return Language{}, fmt.Errorf("%s: tool and preset can not be used together", where)
+	case l.Tool != "":
+		if slices.Contains(seen, l.Tool) {
+			return Language{}, fmt.Errorf("%s: tool cycle: %s", where, strings.Join(append(seen, l.Tool), " -> "))
+		}
+		base, ok := tools[l.Tool]
+		if !ok {
+			return Language{}, fmt.Errorf("%s: unknown tool %q", where, l.Tool)
+		}
+		var err error
+		resolved, err = resolveTool(&base, tools, "tools."+l.Tool, append(seen, l.Tool))
+		if err != nil {
+			return Language{}, err
+		}
+		// The tool is named after the tool it is based on, unless that
+		// one is named itself.
+		if base.Name == "" {
+			resolved.Name = l.Tool
+		}
+	case l.Preset != "":
+		preset, err := loadPreset(l.Preset)
+		if err != nil {
+			return Language{}, fmt.Errorf("%s: %v", where, err)
+		}
+		resolved = preset
+		if resolved.Name == "" {
+			resolved.Name = l.Preset
+		}
+	}
+	if err := l.decodeOnto(&resolved); err != nil {
+		return Language{}, fmt.Errorf("%s: %v", where, err)
+	}
+	return resolved, nil
+}
+
+// decodeOnto decodes the configuration of l again onto dst, so that the
+// settings of l override those of dst and the others are kept.
+func (l *Language) decodeOnto(dst *Language) error {
+	switch {
+	case l.node != nil:
+		if err := l.node.Decode(dst); err != nil {
+			return err
+		}
+		dst.file = l.file
+		return nil
+	case l.raw != nil:
+		return json.Unmarshal(l.raw, dst)
+	}
+	*dst = *l
+	return nil
+}
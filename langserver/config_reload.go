@@ -0,0 +1,195 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// configPollInterval is how often the configuration file is checked for
+// changes.
+var configPollInterval = time.Second
+
+// applyConfig replaces the configuration of the server with config, as
+// loaded from the configuration file, and lints the open documents again.
+// Nothing is replaced when config is invalid.
+func (h *langHandler) applyConfig(config *Config) error {
+	cache, err := newLintCache(config.LintCache)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.commands = *config.Commands
+	h.configs = *config.Languages
+	h.rootMarkers = *config.RootMarkers
+	h.triggerChars = config.TriggerChars
+	if config.LogLevel > 0 {
+		h.loglevel = config.LogLevel
+	}
+	h.lintDebounce = time.Duration(config.LintDebounce)
+	h.formatDebounce = time.Duration(config.FormatDebounce)
+	h.formatWait = time.Duration(config.FormatWaitTimeout)
+	h.commandTimeout = time.Duration(config.CommandTimeout)
+	h.outputLimit = config.CommandOutputLimit
+	h.provideDefinition = config.ProvideDefinition
+	h.lintCache = cache
+	h.includes = config.IncludedFiles
+	uris := make([]DocumentURI, 0, len(h.files))
+	for uri := range h.files {
+		uris = append(uris, uri)
+	}
+	h.mu.Unlock()
+
+	if h.executor != nil {
+		h.executor.setMaxParallel(config.MaxParallel)
+	}
+	go h.registerWatchedFiles()
+	h.lintAgain(uris)
+	return nil
+}
+
+// reloadConfig loads the configuration file again and applies it. Errors
+// are shown to the user, and the configuration in use is kept.
+func (h *langHandler) reloadConfig() error {
+	h.reloadProjects()
+	config, err := LoadConfig(h.filename)
+	if err == nil {
+		err = h.applyConfig(config)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to reload %s: %w", h.filename, err)
+		h.logger.Println(err)
+		h.showMessage(LogError, err.Error())
+		return err
+	}
+	h.logMessage(LogInfo, "Reloaded configuration file")
+	return nil
+}
+
+// reloadProjects loads the project configuration files again, and forgets
+// those which were removed.
+func (h *langHandler) reloadProjects() {
+	h.mu.Lock()
+	files := make([]string, 0, len(h.projects))
+	for file := range h.projects {
+		files = append(files, file)
+	}
+	h.mu.Unlock()
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			h.mu.Lock()
+			delete(h.projects, file)
+			h.mu.Unlock()
+			continue
+		}
+		h.loadProject(file)
+	}
+}
+
+// configFiles returns the files the configuration is loaded from: the
+// configuration file, followed by the files it includes and the project
+// configuration files.
+func (h *langHandler) configFiles() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	projects := make([]string, 0, len(h.projects))
+	for file := range h.projects {
+		projects = append(projects, file)
+	}
+	slices.Sort(projects)
+	files := append([]string{h.filename}, h.includes...)
+	return append(files, projects...)
+}
+
+// configStamps returns strings by configuration file which change when the
+// files are modified. ok is false while the configuration file does not
+// exist.
+func (h *langHandler) configStamps() (stamps map[string]string, ok bool) {
+	stamps = make(map[string]string)
+	for i, fname := range h.configFiles() {
+		info, err := os.Stat(fname)
+		if err != nil {
+			if i == 0 {
+				return nil, false
+			}
+			stamps[fname] = "missing"
+			continue
+		}
+		stamps[fname] = fmt.Sprint(info.Size(), info.ModTime().UnixNano())
+	}
+	return stamps, true
+}
+
+// watchConfig starts reloading the configuration when its files change,
+// until ctx is done. Changes are looked for from the files as they are now.
+func (h *langHandler) watchConfig(ctx context.Context) {
+	last, _ := h.configStamps()
+	go h.pollConfig(ctx, last)
+}
+
+func (h *langHandler) pollConfig(ctx context.Context, last map[string]string) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// A file being replaced may be missing for a moment, and is not
+		// taken for an empty configuration.
+		stamps, ok := h.configStamps()
+		if !ok {
+			continue
+		}
+		// Project configuration files found meanwhile were just loaded.
+		changed := last == nil
+		for fname, stamp := range stamps {
+			if prev, ok := last[fname]; ok && prev != stamp {
+				changed = true
+			}
+		}
+		last = stamps
+		if changed {
+			h.reloadConfig()
+		}
+	}
+}
+
+// lintAgain lints uris again after something they may depend on changed.
+// Clients pulling diagnostics are asked to pull them again instead.
+func (h *langHandler) lintAgain(uris []DocumentURI) {
+	if len(uris) == 0 {
+		return
+	}
+	h.mu.Lock()
+	pull := h.pullDiagnostics
+	if pull {
+		h.workspaceChangedLocked()
+	}
+	refresh := h.diagnosticRefresh
+	conn := h.conn
+	logger := h.logger
+	h.mu.Unlock()
+
+	if !pull {
+		for _, uri := range uris {
+			h.lintRequest(uri, eventTypeSave)
+		}
+		return
+	}
+	if refresh && conn != nil {
+		// Requests to the client can not be waited on by the handler, which
+		// would then not read the response.
+		go func() {
+			if err := conn.Call(context.Background(), "workspace/diagnostic/refresh", nil, nil); err != nil {
+				logger.Println(err)
+			}
+		}()
+	}
+}
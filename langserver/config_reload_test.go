@@ -0,0 +1,74 @@
+package langserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	interval := configPollInterval
+	configPollInterval = 10 * time.Millisecond
+	defer func() { configPollInterval = interval }()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	// The file is replaced as editors do, so that it is never read
+	// half-written.
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(file+".tmp", []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(file+".tmp", file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("version: 2\nlanguages:\n  vim:\n    - lint-command: vint\n")
+	config, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Logger = log.New(io.Discard, "", 0)
+	h := newHandler(config)
+	defer h.shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.watchConfig(ctx)
+
+	lintCommand := func() string {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if len(h.configs["vim"]) == 0 {
+			return ""
+		}
+		return h.configs["vim"][0].LintCommand
+	}
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for lintCommand() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("lint-command should be %q but got: %q", want, lintCommand())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	write("version: 2\nlanguages:\n  vim:\n    - lint-command: vint -\n")
+	waitFor("vint -")
+
+	// A broken file keeps the configuration in use.
+	write("version: 2\nlanguages:\n  vim: [\n")
+	time.Sleep(100 * time.Millisecond)
+	if got := lintCommand(); got != "vint -" {
+		t.Fatalf("lint-command should be kept but got: %q", got)
+	}
+
+	write("version: 2\nlanguages:\n  vim:\n    - lint-command: vint --style\n")
+	waitFor("vint --style")
+}
@@ -1,8 +1,10 @@
 package langserver
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +44,142 @@ languages:
 		t.Fatalf("hover-chars should be %q but got: %q", ".", lua[0].HoverChars)
 	}
 }
+
+func TestLoadConfigTools(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	write("tools/lint.yaml", `tools:
+  vint:
+    lint-command: [vint, '${INPUT}']
+    lint-stdin: true
+    lint-category-map:
+      E: E
+languages:
+  vim:
+    - tool: vint
+`)
+	write("tools/format.yaml", `tools:
+  vim-format:
+    format-command: vim-format
+`)
+	yamlfile := write("config.yaml", `version: 2
+include:
+  - tools/*.yaml
+tools:
+  vint-style:
+    tool: vint
+    lint-command: vint --style
+    lint-category-map:
+      W: W
+languages:
+  vim:
+    - tool: vint-style
+      lint-stdin: false
+    - tool: vim-format
+`)
+
+	config, err := LoadConfig(yamlfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.IncludedFiles) != 2 {
+		t.Fatalf("2 files should be included but got: %v", config.IncludedFiles)
+	}
+	vim := (*config.Languages)["vim"]
+	if len(vim) != 3 {
+		t.Fatalf("vim should have 3 tools but got: %v", vim)
+	}
+	if vim[0].Name != "vint" || vim[0].LintCommand != "vint ${INPUT}" || len(vim[0].LintArgv) != 2 || !vim[0].LintStdin {
+		t.Fatalf("the included tool should be used as is but got: %+v", vim[0])
+	}
+	if vim[1].Name != "vint-style" || vim[1].LintCommand != "vint --style" || vim[1].LintArgv != nil || vim[1].LintStdin {
+		t.Fatalf("the settings of the tool should override the named tool but got: %+v", vim[1])
+	}
+	if len(vim[1].LintCategoryMap) != 2 || len(vim[0].LintCategoryMap) != 1 {
+		t.Fatalf("the category maps should be merged without modifying the named tool but got: %v and %v", vim[1].LintCategoryMap, vim[0].LintCategoryMap)
+	}
+	if vim[2].FormatCommand != "vim-format" {
+		t.Fatalf("a tool of another included file should be found but got: %+v", vim[2])
+	}
+}
+
+func TestLoadConfigToolsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "unknown tool",
+			files: map[string]string{
+				"config.yaml": "version: 2\nlanguages:\n  vim:\n    - tool: vint\n",
+			},
+			want: "config.yaml:4:7: unknown tool \"vint\"",
+		},
+		{
+			name: "tool cycle",
+			files: map[string]string{
+				"config.yaml": "version: 2\ntools:\n  a:\n    tool: b\n  b:\n    tool: a\nlanguages:\n  vim:\n    - tool: a\n",
+			},
+			want: "config.yaml:6:5: tool cycle: a -> b -> a",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"config.yaml": "version: 2\ninclude: [a.yaml]\n",
+				"a.yaml":      "include: [config.yaml]\n",
+			},
+			want: "include cycle:",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"config.yaml": "version: 2\ninclude: [a.yaml]\n",
+			},
+			want: "can not include a.yaml",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("error should contain %q but got: %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestResolveToolsJSON(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"tools": {"vint": {"lintCommand": "vint", "lintStdin": true}},
+		"languages": {"vim": [{"tool": "vint", "lintCommand": "vint --style"}, {"tool": "none"}]}
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = resolveTools(*config.Languages, config.Tools)
+	if err == nil || err.Error() != `languages.vim[1]: unknown tool "none"` {
+		t.Fatalf("the unknown tool should be reported but got: %v", err)
+	}
+	vim := (*config.Languages)["vim"]
+	if vim[0].LintCommand != "vint --style" || !vim[0].LintStdin {
+		t.Fatalf("the settings of the tool should override the named tool but got: %+v", vim[0])
+	}
+}
@@ -7,8 +7,8 @@
 package langserver
 
 import (
+	"sort"
 	"strings"
-	"unicode/utf16"
 )
 
 // OpKind is used to denote the type of operation a line represents.
@@ -31,11 +31,43 @@ const (
 
 // ComputeEdits computes diff edits from 2 string inputs
 func ComputeEdits(_ DocumentURI, before, after string) []TextEdit {
-	ops := operations(splitLines(before), splitLines(after))
+	return computeEdits(before, after, PositionEncodingUTF16)
+}
+
+// computeEdits is ComputeEdits for a client using the position encoding enc.
+// Lines replaced by others are refined into the characters which changed, so
+// that clients keep the cursor, marks and folds of the lines formatted.
+func computeEdits(before, after string, enc PositionEncodingKind) []TextEdit {
+	a, b := splitLines(before), splitLines(after)
+
+	// Most of the lines are usually the same at the start and at the end,
+	// which need not go through the diff.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops := lineOperations(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix, nil)
+
 	edits := make([]TextEdit, 0, len(ops))
-	for _, op := range ops {
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
 		switch op.Kind {
 		case Delete:
+			// Lines replaced by others are followed by the insertions of
+			// those, line by line.
+			var inserted []string
+			for i+1 < len(ops) && ops[i+1].Kind == Insert && ops[i+1].I1 == op.I2 {
+				i++
+				inserted = append(inserted, ops[i].Content...)
+			}
+			if len(inserted) > 0 {
+				edits = append(edits, refineEdits(a[op.I1:op.I2], inserted, op.I1, enc)...)
+				continue
+			}
 			// Delete: unformatted[i1:i2] is deleted.
 			edits = append(edits, TextEdit{Range: Range{
 				Start: Position{Line: op.I1, Character: 0},
@@ -59,22 +91,201 @@ func ComputeEdits(_ DocumentURI, before, after string) []TextEdit {
 	// operations above can reference the line one past the last one, which
 	// does not exist. Clamp those positions to the end of the last line so
 	// clients are not left to guess how to apply out-of-range edits.
-	if before != "" && !strings.HasSuffix(before, "\n") {
-		lines := splitLines(before)
-		lastLine := len(lines) - 1
-		lastChar := len(utf16.Encode([]rune(lines[lastLine])))
-		for i := range edits {
-			if edits[i].Range.Start.Line > lastLine {
-				edits[i].Range.Start = Position{Line: lastLine, Character: lastChar}
-			}
-			if edits[i].Range.End.Line > lastLine {
-				edits[i].Range.End = Position{Line: lastLine, Character: lastChar}
-			}
+	return clampEdits(before, mergeEdits(edits), enc)
+}
+
+// maxMyersLines is the most lines diffed with the Myers algorithm, whose
+// time and memory grow with the number of lines times the number of lines
+// changed. Larger inputs are first split on the lines found once in both,
+// like patience diff does.
+const maxMyersLines = 2000
+
+// lineOperations appends the operations turning the lines a into b to ops,
+// where a starts at line i of the text before and b at line j of the text
+// after.
+func lineOperations(a, b []string, i, j int, ops []*operation) []*operation {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+		i++
+		j++
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a)+len(b) <= maxMyersLines {
+		for _, op := range operations(a, b) {
+			op.I1 += i
+			op.I2 += i
+			op.J1 += j
+			ops = append(ops, op)
+		}
+		return ops
+	}
+
+	anchors := uniqueCommonLines(a, b)
+	if len(anchors) == 0 {
+		// Nothing to align the lines on: they are all replaced.
+		if len(a) > 0 {
+			ops = append(ops, &operation{Kind: Delete, I1: i, I2: i + len(a), J1: j})
+		}
+		if len(b) > 0 {
+			ops = append(ops, &operation{Kind: Insert, Content: b, I1: i + len(a), I2: i + len(a), J1: j})
+		}
+		return ops
+	}
+	x, y := 0, 0
+	for _, anchor := range anchors {
+		ops = lineOperations(a[x:anchor[0]], b[y:anchor[1]], i+x, j+y, ops)
+		x, y = anchor[0]+1, anchor[1]+1
+	}
+	return lineOperations(a[x:], b[y:], i+x, j+y, ops)
+}
+
+// uniqueCommonLines returns the indices in a and b of the longest sequence
+// of lines found once in a and once in b, in the same order in both.
+func uniqueCommonLines(a, b []string) [][2]int {
+	type count struct{ a, b, j int }
+	counts := make(map[string]*count)
+	for _, line := range a {
+		c, ok := counts[line]
+		if !ok {
+			c = &count{}
+			counts[line] = c
+		}
+		c.a++
+	}
+	for j, line := range b {
+		if c, ok := counts[line]; ok {
+			c.b++
+			c.j = j
+		}
+	}
+	var pairs [][2]int
+	for i, line := range a {
+		if c := counts[line]; c.a == 1 && c.b == 1 {
+			pairs = append(pairs, [2]int{i, c.j})
+		}
+	}
+
+	// Longest increasing subsequence of the indices in b, by patience
+	// sorting: tails[n] is the pair ending the best sequence of n+1 pairs.
+	var tails []int
+	prev := make([]int, len(pairs))
+	for k, pair := range pairs {
+		n := sort.Search(len(tails), func(n int) bool { return pairs[tails[n]][1] >= pair[1] })
+		prev[k] = -1
+		if n > 0 {
+			prev[k] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, k)
+		} else {
+			tails[n] = k
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	anchors := make([][2]int, len(tails))
+	for n, k := len(tails)-1, tails[len(tails)-1]; n >= 0; n, k = n-1, prev[k] {
+		anchors[n] = pairs[k]
+	}
+	return anchors
+}
+
+// maxRefineLen is the most characters of replaced and replacing text diffed
+// character by character, beyond which only their common prefix and suffix
+// are kept.
+const maxRefineLen = 4096
+
+// refineEdits returns the edits replacing the lines deleted, starting at
+// line, with the lines inserted, changing only the characters which differ.
+// Lines are refined pairwise when as many are inserted as deleted.
+func refineEdits(deleted, inserted []string, line int, enc PositionEncodingKind) []TextEdit {
+	if len(deleted) == len(inserted) {
+		var edits []TextEdit
+		for i := range deleted {
+			edits = append(edits, characterEdits(deleted[i], inserted[i], line+i, enc)...)
+		}
+		return edits
+	}
+	return characterEdits(strings.Join(deleted, ""), strings.Join(inserted, ""), line, enc)
+}
+
+// characterEdits returns the edits turning a, which starts at line, into b.
+func characterEdits(a, b string, line int, enc PositionEncodingKind) []TextEdit {
+	ra, rb := []rune(a), []rune(b)
+
+	// positions are the positions of the runes of a, and of its end.
+	positions := make([]Position, len(ra)+1)
+	pos := Position{Line: line}
+	for i, r := range ra {
+		positions[i] = pos
+		if r == '\n' {
+			pos = Position{Line: pos.Line + 1}
+		} else {
+			pos.Character += encodedLen(string(r), enc)
+		}
+	}
+	positions[len(ra)] = pos
+
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+	ra, rb = ra[prefix:len(ra)-suffix], rb[prefix:len(rb)-suffix]
+	if len(ra)+len(rb) > maxRefineLen {
+		return []TextEdit{{
+			Range:   Range{Start: positions[prefix], End: positions[prefix+len(ra)]},
+			NewText: string(rb),
+		}}
+	}
+
+	sa, sb := make([]string, len(ra)), make([]string, len(rb))
+	for i, r := range ra {
+		sa[i] = string(r)
+	}
+	for i, r := range rb {
+		sb[i] = string(r)
+	}
+	var edits []TextEdit
+	for _, op := range operations(sa, sb) {
+		switch op.Kind {
+		case Delete:
+			edits = append(edits, TextEdit{Range: Range{
+				Start: positions[prefix+op.I1],
+				End:   positions[prefix+op.I2],
+			}})
+		case Insert:
+			edits = append(edits, TextEdit{
+				Range:   Range{Start: positions[prefix+op.I1], End: positions[prefix+op.I1]},
+				NewText: strings.Join(op.Content, ""),
+			})
 		}
 	}
 	return edits
 }
 
+// mergeEdits merges the edits, which are sorted, where one ends at the start
+// of the next, such as deleted characters followed by those inserted in
+// their place.
+func mergeEdits(edits []TextEdit) []TextEdit {
+	merged := edits[:0]
+	for _, edit := range edits {
+		if n := len(merged); n > 0 && merged[n-1].Range.End == edit.Range.Start {
+			merged[n-1].Range.End = edit.Range.End
+			merged[n-1].NewText += edit.NewText
+			continue
+		}
+		merged = append(merged, edit)
+	}
+	return merged
+}
+
 type operation struct {
 	Kind    OpKind
 	Content []string // content from b
@@ -1,6 +1,7 @@
 package langserver
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -76,3 +77,93 @@ func TestComputeEditsTrailingNewline(t *testing.T) {
 		t.Fatalf("applying edits should produce %q but got: %q", after, got)
 	}
 }
+
+func TestComputeEditsCharacters(t *testing.T) {
+	before := "func main()  {\n\tfmt.Println( \"𝄞\" )\n}\n"
+	after := "func main() {\n\tfmt.Println(\"𝄞\")\n}\n"
+
+	edits := computeEdits(before, after, PositionEncodingUTF16)
+	want := []TextEdit{
+		{Range: Range{Position{0, 12}, Position{0, 13}}},
+		{Range: Range{Position{1, 13}, Position{1, 14}}},
+		{Range: Range{Position{1, 18}, Position{1, 19}}},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("edits should be %v but got: %v", want, edits)
+	}
+	for i := range want {
+		if edits[i] != want[i] {
+			t.Fatalf("edits should be %v but got: %v", want, edits)
+		}
+	}
+	if got := applyTextEdits(before, edits, PositionEncodingUTF16); got != after {
+		t.Fatalf("applying edits should produce %q but got: %q", after, got)
+	}
+}
+
+func TestComputeEditsMerged(t *testing.T) {
+	before := "a := foo(1,2)\nb\n"
+	after := "a := bar(1, 2)\nc\nd\n"
+
+	edits := ComputeEdits("file:///foo", before, after)
+	for i := 1; i < len(edits); i++ {
+		if edits[i-1].Range.End == edits[i].Range.Start {
+			t.Fatalf("adjacent edits should be merged but got: %v", edits)
+		}
+	}
+	if got := applyEdits(t, before, edits); got != after {
+		t.Fatalf("applying edits should produce %q but got: %q", after, got)
+	}
+}
+
+func TestComputeEditsLarge(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&b, "\tx%d := %d\n", i, i)
+	}
+	before := b.String()
+	after := strings.Replace(before, "\tx100 := 100\n", "\tx100  :=  100\n", 1) + "\n"
+
+	edits := ComputeEdits("file:///foo", before, after)
+	if len(edits) != 3 {
+		t.Fatalf("3 edits should be computed but got: %v", edits)
+	}
+	if got := applyEdits(t, before, edits); got != after {
+		t.Fatal("applying edits should produce the text after")
+	}
+}
+
+func TestComputeEditsLargeScattered(t *testing.T) {
+	var before, after strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&before, "\tx%d := %d\n", i, i)
+		switch {
+		case i%100 == 0:
+			fmt.Fprintf(&after, "\tx%d  :=  %d\n", i, i)
+		case i%100 == 50:
+			fmt.Fprintf(&after, "\tx%d := %d\n\n\n", i, i)
+		default:
+			fmt.Fprintf(&after, "\tx%d := %d\n", i, i)
+		}
+	}
+
+	// Changes all over the file are too many for the Myers algorithm alone,
+	// and are found between the lines which did not change.
+	edits := ComputeEdits("file:///foo", before.String(), after.String())
+	if len(edits) != 600 {
+		t.Fatalf("600 edits should be computed but got: %d", len(edits))
+	}
+	if got := applyEdits(t, before.String(), edits); got != after.String() {
+		t.Fatal("applying edits should produce the text after")
+	}
+}
+
+func TestComputeEditsLargeWithoutUniqueLines(t *testing.T) {
+	before := strings.Repeat("a\nb\n", 2000)
+	after := strings.Repeat("b\na\n", 2000)
+
+	edits := ComputeEdits("file:///foo", before, after)
+	if got := applyEdits(t, before, edits); got != after {
+		t.Fatalf("applying edits should produce %q but got: %q", after, got)
+	}
+}
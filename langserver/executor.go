@@ -0,0 +1,148 @@
+package langserver
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// priority orders the commands waiting for the executor. Higher priorities
+// run first, and commands of the same priority run in the order they came.
+type priority int
+
+const (
+	// priorityBackground is for lints of documents the user is not editing,
+	// e.g. those opened along with a session.
+	priorityBackground priority = iota
+	// priorityActive is for lints of the document last changed or saved.
+	priorityActive
+	// priorityInteractive is for requests the user is waiting on, such as
+	// formatting, hover and completion.
+	priorityInteractive
+)
+
+// job is a command waiting for the executor, or running.
+type job struct {
+	tool     string
+	limit    int
+	priority priority
+	uri      DocumentURI
+	seq      uint64
+	ready    chan struct{}
+}
+
+// executor limits how many commands run at once, overall and per tool, and
+// starts the waiting commands by priority.
+type executor struct {
+	mu          sync.Mutex
+	maxParallel int
+	running     int
+	tools       map[string]int
+	queue       []*job
+	active      DocumentURI
+	seq         uint64
+}
+
+func newExecutor(maxParallel int) *executor {
+	e := &executor{tools: make(map[string]int)}
+	e.setMaxParallel(maxParallel)
+	return e
+}
+
+// setMaxParallel sets how many commands run at once. Zero or less means the
+// number of CPUs.
+func (e *executor) setMaxParallel(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxParallel = n
+	e.dispatch()
+}
+
+// setActive marks uri as the document being edited, whose lints run ahead of
+// the others.
+func (e *executor) setActive(uri DocumentURI) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active = uri
+}
+
+// acquire waits until j may run, and returns a func to call once it is done.
+// A job whose ctx is done while waiting is dropped from the queue. A nil
+// executor runs everything at once.
+func (e *executor) acquire(ctx context.Context, j *job) (func(), error) {
+	if e == nil {
+		return func() {}, nil
+	}
+	j.ready = make(chan struct{})
+	e.mu.Lock()
+	e.seq++
+	j.seq = e.seq
+	e.queue = append(e.queue, j)
+	e.dispatch()
+	e.mu.Unlock()
+
+	release := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.running--
+		if e.tools[j.tool]--; e.tools[j.tool] <= 0 {
+			delete(e.tools, j.tool)
+		}
+		e.dispatch()
+	}
+	select {
+	case <-j.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	e.mu.Lock()
+	for i, queued := range e.queue {
+		if queued == j {
+			e.queue = append(e.queue[:i], e.queue[i+1:]...)
+			e.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	e.mu.Unlock()
+	// Started while ctx was being done.
+	release()
+	return nil, ctx.Err()
+}
+
+func (e *executor) priorityOf(j *job) priority {
+	if j.priority == priorityBackground && j.uri != "" && j.uri == e.active {
+		return priorityActive
+	}
+	return j.priority
+}
+
+// dispatch starts the waiting jobs that fit in the limits, highest priority
+// first. It must be called with e.mu held.
+func (e *executor) dispatch() {
+	for e.running < e.maxParallel {
+		best := -1
+		for i, j := range e.queue {
+			if j.limit > 0 && e.tools[j.tool] >= j.limit {
+				continue
+			}
+			if best == -1 || e.priorityOf(j) > e.priorityOf(e.queue[best]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			return
+		}
+		j := e.queue[best]
+		e.queue = append(e.queue[:best], e.queue[best+1:]...)
+		e.running++
+		e.tools[j.tool]++
+		close(j.ready)
+	}
+}
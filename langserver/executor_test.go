@@ -0,0 +1,130 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func acquireAsync(e *executor, ctx context.Context, j *job) <-chan func() {
+	ch := make(chan func(), 1)
+	go func() {
+		release, err := e.acquire(ctx, j)
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- release
+	}()
+	return ch
+}
+
+func waitQueued(t *testing.T, e *executor, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		e.mu.Lock()
+		queued := len(e.queue)
+		e.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d jobs should be queued", n)
+}
+
+func TestExecutorPriority(t *testing.T) {
+	e := newExecutor(1)
+	release, err := e.acquire(context.Background(), &job{tool: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.setActive("file:///active")
+	var order []string
+	done := make(chan string)
+	jobs := []*job{
+		{tool: "background", uri: "file:///other"},
+		{tool: "active", uri: "file:///active"},
+		{tool: "interactive", priority: priorityInteractive},
+	}
+	for i, j := range jobs {
+		ch := acquireAsync(e, context.Background(), j)
+		waitQueued(t, e, i+1)
+		go func(j *job) {
+			release := <-ch
+			done <- j.tool
+			release()
+		}(j)
+	}
+
+	release()
+	for range jobs {
+		order = append(order, <-done)
+	}
+	want := []string{"interactive", "active", "background"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("jobs should run in order %q but got: %q", want, order)
+	}
+}
+
+func TestExecutorToolLimit(t *testing.T) {
+	e := newExecutor(2)
+	release, err := e.acquire(context.Background(), &job{tool: "eslint", limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eslint := acquireAsync(e, context.Background(), &job{tool: "eslint", limit: 1})
+	other := acquireAsync(e, context.Background(), &job{tool: "shellcheck", limit: 1})
+	select {
+	case r := <-other:
+		r()
+	case <-time.After(5 * time.Second):
+		t.Fatal("another tool should run while eslint is at its limit")
+	}
+	select {
+	case <-eslint:
+		t.Fatal("eslint should wait for its running command")
+	default:
+	}
+
+	release()
+	select {
+	case r := <-eslint:
+		r()
+	case <-time.After(5 * time.Second):
+		t.Fatal("eslint should run once its running command is done")
+	}
+}
+
+func TestExecutorCancel(t *testing.T) {
+	e := newExecutor(1)
+	release, err := e.acquire(context.Background(), &job{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := e.acquire(ctx, &job{})
+		errc <- err
+	}()
+	waitQueued(t, e, 1)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("error should be canceled but got: %v", err)
+	}
+	waitQueued(t, e, 0)
+
+	release()
+	e.mu.Lock()
+	running := e.running
+	e.mu.Unlock()
+	if running != 0 {
+		t.Fatalf("a canceled job should not run but %d are running", running)
+	}
+}
@@ -0,0 +1,212 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ExplainParams are the params of the efm-langserver/explain request. An
+// empty LanguageID is the one of the open document, or is guessed from the
+// extension of the file.
+type ExplainParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	LanguageID   string                 `json:"languageId,omitempty"`
+}
+
+// Explanation tells which tools apply to a document, and how they are run.
+type Explanation struct {
+	URI        DocumentURI       `json:"uri"`
+	LanguageID string            `json:"languageId"`
+	Tools      []ToolExplanation `json:"tools"`
+}
+
+// ToolExplanation tells how a tool is run for a capability, or why it is
+// not.
+type ToolExplanation struct {
+	Capability string `json:"capability"`
+	// Language is the language the tool is configured for, which is
+	// wildcard for the tools of all languages.
+	Language string `json:"language"`
+	Tool     string `json:"tool"`
+	// Source is the configuration file of the tool, if known.
+	Source string `json:"source,omitempty"`
+	// Skipped tells why the tool does not run. The reasons starting with an
+	// event, like "on open:", only skip the tool for the event.
+	Skipped    []string `json:"skipped,omitempty"`
+	Runs       bool     `json:"runs"`
+	RootPath   string   `json:"rootPath"`
+	RootReason string   `json:"rootReason"`
+	Command    string   `json:"command"`
+	Argv       []string `json:"argv,omitempty"`
+	Stdin      bool     `json:"stdin"`
+	Env        []string `json:"env,omitempty"`
+}
+
+func (h *langHandler) handleExplain(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params ExplainParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	uri := params.TextDocument.URI
+	languageID := params.LanguageID
+	if languageID == "" {
+		h.mu.Lock()
+		if f, ok := h.files[uri]; ok {
+			languageID = f.LanguageID
+		}
+		h.mu.Unlock()
+	}
+	h.discoverProject(uri)
+	return h.explain(uri, languageID)
+}
+
+// explain explains the tools applying to the document uri of languageID.
+// Placeholders which depend on the request, like ${POSITION} or the word
+// under the cursor for hover, are left as they are, and so are the
+// formatting options.
+func (h *langHandler) explain(uri DocumentURI, languageID string) (*Explanation, error) {
+	fname, err := fromURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri: %v: %v", err, uri)
+	}
+	fname = filepath.ToSlash(fname)
+	if languageID == "" {
+		languageID = guessLanguageID(fname)
+	}
+
+	firstLine := h.firstLine(uri)
+	h.mu.Lock()
+	langConfigs := append([]Language{}, h.languageToolsLocked(uri, languageID)...)
+	wildcardConfigs := append([]Language{}, h.languageToolsLocked(uri, wildcard)...)
+	matched := h.matchedToolsLocked(uri, languageID, fname, firstLine)
+	h.mu.Unlock()
+
+	e := &Explanation{URI: uri, LanguageID: languageID, Tools: []ToolExplanation{}}
+	for _, capability := range []string{"lint", "format", "symbol", "completion", "hover"} {
+		for i, config := range langConfigs {
+			if t, ok := h.explainTool(capability, config, i, fname, firstLine, false); ok {
+				t.Language = languageID
+				e.Tools = append(e.Tools, t)
+			}
+		}
+		for i, m := range matched {
+			if t, ok := h.explainTool(capability, m.config, i, fname, firstLine, false); ok {
+				t.Language = m.language
+				e.Tools = append(e.Tools, t)
+			}
+		}
+		for i, config := range wildcardConfigs {
+			if t, ok := h.explainTool(capability, config, i, fname, firstLine, true); ok {
+				t.Language = wildcard
+				e.Tools = append(e.Tools, t)
+			}
+		}
+	}
+	return e, nil
+}
+
+// explainTool explains the i-th tool config for capability, and reports
+// whether the tool provides it at all. The filters of the handlers but
+// exclude-patterns, filename-patterns and shebang do not apply to wildcard
+// tools.
+func (h *langHandler) explainTool(capability string, config Language, i int, fname, firstLine string, isWildcard bool) (ToolExplanation, bool) {
+	t := ToolExplanation{
+		Capability: capability,
+		Tool:       checkToolName(config, i),
+		Source:     config.file,
+		Env:        config.Env,
+	}
+	t.RootPath, t.RootReason = h.findRootPathReason(fname, config)
+	if reason := documentSkipReason(config, fname, firstLine); reason != "" {
+		t.Skipped = []string{reason}
+	}
+
+	h.mu.Lock()
+	rootPath := h.rootPath
+	h.mu.Unlock()
+
+	switch capability {
+	case "lint":
+		if config.LintCommand == "" {
+			return t, false
+		}
+		if !isWildcard && len(t.Skipped) == 0 {
+			if reason := lintSkipReason(config, fname, eventTypeSave); reason != "" {
+				t.Skipped = []string{reason}
+			} else {
+				for _, event := range []struct {
+					name string
+					typ  eventType
+				}{{"open", eventTypeOpen}, {"change", eventTypeChange}} {
+					if reason := lintSkipReason(config, fname, event.typ); reason != "" {
+						t.Skipped = append(t.Skipped, "on "+event.name+": "+reason)
+					}
+				}
+			}
+		}
+		t.Command, t.Argv = lintCommandLine(config, fname, t.RootPath)
+		t.Stdin = config.LintStdin
+	case "format":
+		if config.FormatCommand == "" {
+			return t, false
+		}
+		if !isWildcard && len(t.Skipped) == 0 {
+			if reason := requireMarkerSkipReason(config, fname); reason != "" {
+				t.Skipped = []string{reason}
+			}
+		}
+		t.Command, t.Argv = formatCommandLine(config, fname, rootPath)
+		t.Stdin = config.FormatStdin
+	case "symbol":
+		if config.SymbolCommand == "" {
+			return t, false
+		}
+		t.Command, t.Argv = explainCommandLine(config.SymbolCommand, config.SymbolArgv, config.SymbolStdin, fname, rootPath)
+		t.Stdin = config.SymbolStdin
+	case "completion":
+		if config.CompletionCommand == "" {
+			return t, false
+		}
+		t.Command, t.Argv = explainCommandLine(config.CompletionCommand, config.CompletionArgv, config.CompletionStdin, fname, rootPath)
+		t.Stdin = config.CompletionStdin
+	case "hover":
+		if config.HoverCommand == "" {
+			return t, false
+		}
+		// ${INPUT} is the word under the cursor.
+		t.Command, t.Argv = explainCommandLine(config.HoverCommand, config.HoverArgv, config.HoverStdin, "", "")
+		t.Stdin = config.HoverStdin
+	}
+	t.Runs = len(t.Skipped) == 0 || strings.HasPrefix(t.Skipped[0], "on ")
+	return t, true
+}
+
+// explainCommandLine returns the command line of the symbol, completion and
+// hover commands, which have their ${INPUT} replaced with fname unless it is
+// empty.
+func explainCommandLine(command string, argv []string, stdin bool, fname, rootPath string) (string, []string) {
+	if len(argv) > 0 {
+		argv = withInput(argv, stdin)
+		if fname != "" {
+			argv = replaceArgvInputFilename(argv, fname, rootPath)
+		}
+		return strings.Join(argv, " "), argv
+	}
+	if !stdin && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	if fname != "" {
+		command = replaceCommandInputFilename(command, fname, rootPath)
+	}
+	return command, nil
+}
@@ -0,0 +1,93 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "project")
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".vintrc.yaml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(root, "src", "a.vim")
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand: "vint",
+					LintOnSave:  true,
+					RootMarkers: []string{".vintrc.yaml"},
+					Env:         []string{"VINT=1"},
+				},
+				{
+					Name:          "vimfmt",
+					FormatCommand: "vimfmt -",
+					FormatStdin:   true,
+					RequireMarker: true,
+					RootMarkers:   []string{".vimfmt"},
+				},
+			},
+			wildcard: {
+				{LintCommand: "codespell", LintTempfile: true},
+			},
+		},
+	}
+	e, err := h.explain(toURI(fname), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.LanguageID != "vim" {
+		t.Fatalf("the language should be guessed but got: %q", e.LanguageID)
+	}
+
+	want := []ToolExplanation{
+		{
+			Capability: "lint",
+			Language:   "vim",
+			Tool:       "vint",
+			Skipped:    []string{"on open: lint-after-open is not set", "on change: lint-on-save is set"},
+			Runs:       true,
+			RootPath:   root,
+			RootReason: "root-markers of the tool",
+			Command:    "vint " + filepath.ToSlash(fname),
+			Env:        []string{"VINT=1"},
+		},
+		{
+			Capability: "lint",
+			Language:   wildcard,
+			Tool:       "codespell",
+			Runs:       true,
+			RootPath:   base,
+			RootReason: "root path of the client",
+			Command:    "codespell ${TEMPFILE}",
+		},
+		{
+			Capability: "format",
+			Language:   "vim",
+			Tool:       "vimfmt",
+			Skipped:    []string{"require-marker is set and none of root-markers is found"},
+			RootPath:   base,
+			RootReason: "root path of the client",
+			Command:    "vimfmt -",
+			Stdin:      true,
+		},
+	}
+	if !reflect.DeepEqual(e.Tools, want) {
+		t.Fatalf("the tools should be explained as:\n%+v\nbut got:\n%+v", want, e.Tools)
+	}
+}
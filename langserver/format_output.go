@@ -0,0 +1,65 @@
+package langserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+// Kinds of format-output.
+const (
+	formatOutputText            = "text"
+	formatOutputDiff            = "diff"
+	formatOutputXMLReplacements = "xml-replacements"
+	formatOutputInPlaceTempfile = "in-place-tempfile"
+)
+
+// formatReplacements is the output of clang-format
+// --output-replacements-xml, whose offsets count bytes of the input.
+type formatReplacements struct {
+	Replacements []struct {
+		Offset int    `xml:"offset,attr"`
+		Length int    `xml:"length,attr"`
+		Text   string `xml:",chardata"`
+	} `xml:"replacement"`
+}
+
+// formatOutputEdits converts the output b of a formatter of fname, whose
+// format-output is kind, into edits against text.
+func formatOutputEdits(kind string, b []byte, text, fname string, enc PositionEncodingKind) ([]TextEdit, error) {
+	edits := []TextEdit{}
+	switch kind {
+	case formatOutputDiff:
+		files, err := parseUnifiedDiff(b)
+		if err != nil {
+			return nil, err
+		}
+		if diff := diffFileFor(files, fname); diff != nil {
+			edits = append(edits, hunkEdits(text, diff.Hunks, enc)...)
+		}
+	case formatOutputXMLReplacements:
+		var replacements formatReplacements
+		if err := xml.Unmarshal(b, &replacements); err != nil {
+			return nil, fmt.Errorf("invalid replacements: %v", err)
+		}
+		for _, r := range replacements.Replacements {
+			if r.Offset < 0 || r.Length < 0 || r.Offset+r.Length > len(text) {
+				return nil, fmt.Errorf("invalid replacement: offset %d length %d", r.Offset, r.Length)
+			}
+			edits = append(edits, TextEdit{
+				Range: Range{
+					Start: offsetToPosition(text, r.Offset, enc),
+					End:   offsetToPosition(text, r.Offset+r.Length, enc),
+				},
+				NewText: r.Text,
+			})
+		}
+		sort.SliceStable(edits, func(i, j int) bool {
+			a, b := edits[i].Range.Start, edits[j].Range.Start
+			return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+		})
+	default:
+		return nil, fmt.Errorf("invalid format-output: %v", kind)
+	}
+	return edits, nil
+}
@@ -0,0 +1,82 @@
+package langserver
+
+import (
+	"testing"
+)
+
+func TestFormatOutputEdits(t *testing.T) {
+	text := "int  main(){\nreturn 0;}\n"
+	tests := []struct {
+		name   string
+		kind   string
+		output string
+		want   string
+	}{
+		{
+			name: "diff",
+			kind: formatOutputDiff,
+			output: "--- a.c.orig\n+++ a.c\n@@ -1,2 +1,3 @@\n" +
+				"-int  main(){\n-return 0;}\n+int main() {\n+  return 0;\n+}\n",
+			want: "int main() {\n  return 0;\n}\n",
+		},
+		{
+			name:   "empty diff",
+			kind:   formatOutputDiff,
+			output: "",
+			want:   text,
+		},
+		{
+			name: "xml-replacements",
+			kind: formatOutputXMLReplacements,
+			output: "<?xml version='1.0'?>\n<replacements xml:space='preserve' incomplete_format='false'>\n" +
+				"<replacement offset='3' length='2'> </replacement>\n" +
+				"<replacement offset='11' length='0'> </replacement>\n" +
+				"<replacement offset='12' length='1'>&#10;  </replacement>\n" +
+				"<replacement offset='22' length='0'>&#10;</replacement>\n" +
+				"</replacements>\n",
+			want: "int main() {\n  return 0;\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			edits, err := formatOutputEdits(test.kind, []byte(test.output), text, "/src/a.c", PositionEncodingUTF16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := applyTextEdits(text, edits, PositionEncodingUTF16); got != test.want {
+				t.Fatalf("edits should produce %q but got: %q", test.want, got)
+			}
+		})
+	}
+
+	_, err := formatOutputEdits(formatOutputXMLReplacements, []byte("<replacements><replacement offset='30' length='1'/></replacements>"), text, "/src/a.c", PositionEncodingUTF16)
+	if err == nil {
+		t.Fatal("a replacement past the end of the text should be reported")
+	}
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	s := "a\r\n𝄞b\rc"
+	tests := []struct {
+		offset int
+		enc    PositionEncodingKind
+		want   Position
+	}{
+		{0, PositionEncodingUTF16, Position{0, 0}},
+		{3, PositionEncodingUTF16, Position{1, 0}},
+		{8, PositionEncodingUTF16, Position{1, 3}},
+		{8, PositionEncodingUTF8, Position{1, 5}},
+		{8, PositionEncodingUTF32, Position{1, 2}},
+		{9, PositionEncodingUTF16, Position{2, 0}},
+		{100, PositionEncodingUTF16, Position{2, 1}},
+	}
+	for _, test := range tests {
+		got := offsetToPosition(s, test.offset, test.enc)
+		if got != test.want {
+			t.Errorf("offsetToPosition(%q, %d, %v) should be %v but got: %v", s, test.offset, test.enc, test.want, got)
+		}
+		if back := positionToOffset(s, got, test.enc); test.offset <= len(s) && back != test.offset {
+			t.Errorf("positionToOffset of %v should be %d but got: %d", got, test.offset, back)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package langserver
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// compileGlob compiles a glob pattern of the protocol, which has "*" and
+// "?" matching within a path segment, "**" matching any number of segments,
+// "{a,b}" and "[...]". Patterns not starting with "/" match at any depth,
+// so "*.go" matches Go files in every directory.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if runtime.GOOS == "windows" {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "**") {
+		b.WriteString("(?:.*/)?")
+	}
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '{':
+			braces++
+			b.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			b.WriteString(")")
+		case c == ',' && braces > 0:
+			b.WriteString("|")
+		case c == '[' && strings.IndexByte(pattern[i+1:], ']') > 0:
+			end := i + 1 + strings.IndexByte(pattern[i+1:], ']')
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globCache keeps the compiled glob patterns by pattern, with nil for
+// invalid ones, as the same patterns of the configuration are matched over
+// and over.
+var globCache sync.Map
+
+// matchGlob reports whether fname matches the glob pattern, which is false
+// for invalid patterns.
+func matchGlob(pattern, fname string) bool {
+	v, ok := globCache.Load(pattern)
+	if !ok {
+		re, err := compileGlob(pattern)
+		if err != nil {
+			re = nil
+		}
+		v, _ = globCache.LoadOrStore(pattern, re)
+	}
+	re := v.(*regexp.Regexp)
+	if re == nil {
+		return false
+	}
+	return re.MatchString(filepath.ToSlash(fname))
+}
@@ -0,0 +1,32 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *langHandler) handleCodeActionResolve(ctx context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params struct {
+		CodeAction
+		Data *fixActionData `json:"data"`
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	action := params.CodeAction
+	if params.Data == nil || params.Edit != nil {
+		return action, nil
+	}
+	action.Data = params.Data
+	if err := h.resolveFixAction(ctx, &action, params.Data); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -42,6 +44,9 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 	var hasFormatCommand bool
 	var hasRangeFormatCommand bool
 	var hasDefinitionCommand bool
+	var hasWorkspaceLint bool
+	var hasFormatOnSave bool
+	var onTypeChars []string
 
 	if params.InitializationOptions != nil {
 		hasCompletionCommand = params.InitializationOptions.Completion
@@ -72,10 +77,26 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 			if v.SymbolCommand != "" {
 				hasSymbolCommand = true
 			}
+			if v.LintWorkspace {
+				hasWorkspaceLint = true
+			}
+			if v.LintFixCommand != "" || (v.LintOutputFormat != "" && v.LintOutputFormat != lintOutputErrorformat) {
+				hasCodeActionCommand = true
+			}
 			if v.FormatCommand != "" {
 				hasFormatCommand = true
-				if v.FormatCanRange {
+				if canFormatRange(v) {
 					hasRangeFormatCommand = true
+					// Formatters formatting the whole document are not
+					// run on type.
+					for _, c := range v.FormatOnTypeChars {
+						if !slices.Contains(onTypeChars, c) {
+							onTypeChars = append(onTypeChars, c)
+						}
+					}
+				}
+				if v.FormatOnSave {
+					hasFormatOnSave = true
 				}
 			}
 		}
@@ -91,16 +112,83 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 		}
 	}
 
+	// Clients are only asked to wait for formatting before saving when a
+	// formatter runs on save, which needs the sync to be sent as options.
+	var textDocumentSync any = TDSKIncremental
+	if hasFormatOnSave {
+		textDocumentSync = &TextDocumentSyncOptions{
+			OpenClose:         true,
+			Change:            TDSKIncremental,
+			WillSaveWaitUntil: true,
+			Save:              true,
+		}
+	}
+	var onTypeFormatting *DocumentOnTypeFormattingOptions
+	if len(onTypeChars) > 0 {
+		sort.Strings(onTypeChars)
+		onTypeFormatting = &DocumentOnTypeFormattingOptions{
+			FirstTriggerCharacter: onTypeChars[0],
+			MoreTriggerCharacter:  onTypeChars[1:],
+		}
+	}
+
+	// Clients supporting pull diagnostics get them on demand, and are no
+	// longer sent textDocument/publishDiagnostics.
+	var diagnostic *DiagnosticOptions
+	if params.Capabilities.TextDocument != nil && params.Capabilities.TextDocument.Diagnostic != nil {
+		diagnostic = &DiagnosticOptions{
+			InterFileDependencies: hasWorkspaceLint,
+			WorkspaceDiagnostics:  true,
+		}
+		h.mu.Lock()
+		h.pullDiagnostics = true
+		h.mu.Unlock()
+	}
+
+	// The edits of lint-fix-command are only computed once an action is
+	// chosen by clients able to resolve them.
+	var codeActionProvider any = hasCodeActionCommand
+	if textDocument := params.Capabilities.TextDocument; textDocument != nil && textDocument.CodeAction != nil && textDocument.CodeAction.ResolveSupport != nil {
+		if slices.Contains(textDocument.CodeAction.ResolveSupport.Properties, "edit") {
+			h.mu.Lock()
+			h.resolveCodeActions = true
+			h.mu.Unlock()
+			if hasCodeActionCommand {
+				codeActionProvider = &CodeActionOptions{ResolveProvider: true}
+			}
+		}
+	}
+
+	if workspace := params.Capabilities.Workspace; workspace != nil {
+		h.mu.Lock()
+		h.watchFiles = workspace.DidChangeWatchedFiles != nil && workspace.DidChangeWatchedFiles.DynamicRegistration
+		h.diagnosticRefresh = workspace.Diagnostics != nil && workspace.Diagnostics.RefreshSupport
+		h.mu.Unlock()
+	}
+
+	// The protocol defaults to UTF-16, so the encoding is only answered to
+	// clients offering a choice.
+	var positionEncoding PositionEncodingKind
+	if params.Capabilities.General != nil && len(params.Capabilities.General.PositionEncodings) > 0 {
+		positionEncoding = negotiatePositionEncoding(params.Capabilities.General.PositionEncodings)
+		h.mu.Lock()
+		h.positionEncoding = positionEncoding
+		h.mu.Unlock()
+	}
+
 	return InitializeResult{
 		Capabilities: ServerCapabilities{
-			TextDocumentSync:           TDSKFull,
+			PositionEncoding:           positionEncoding,
+			TextDocumentSync:           textDocumentSync,
 			DocumentFormattingProvider: hasFormatCommand,
 			RangeFormattingProvider:    hasRangeFormatCommand,
+			OnTypeFormattingProvider:   onTypeFormatting,
 			DocumentSymbolProvider:     hasSymbolCommand,
 			DefinitionProvider:         hasDefinitionCommand,
 			CompletionProvider:         completion,
 			HoverProvider:              hasHoverCommand,
-			CodeActionProvider:         hasCodeActionCommand,
+			CodeActionProvider:         codeActionProvider,
+			DiagnosticProvider:         diagnostic,
 			Workspace: &ServerCapabilitiesWorkspace{
 				WorkspaceFolders: WorkspaceFoldersServerCapabilities{
 					Supported:           true,
@@ -110,3 +198,24 @@ func (h *langHandler) handleInitialize(_ context.Context, conn *jsonrpc2.Conn, r
 		},
 	}, nil
 }
+
+func (h *langHandler) handleInitialized(_ context.Context, _ *jsonrpc2.Conn, _ *jsonrpc2.Request) (result any, err error) {
+	go h.registerWatchedFiles()
+
+	if h.filename != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.mu.Lock()
+		if h.isShutdown {
+			h.mu.Unlock()
+			cancel()
+			return nil, nil
+		}
+		if h.stopWatchConfig != nil {
+			h.stopWatchConfig()
+		}
+		h.stopWatchConfig = cancel
+		h.mu.Unlock()
+		h.watchConfig(ctx)
+	}
+	return nil, nil
+}
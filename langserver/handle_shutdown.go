@@ -18,9 +18,15 @@ func (h *langHandler) shutdown() {
 		return
 	}
 	h.isShutdown = true
+	if h.stopWatchConfig != nil {
+		h.stopWatchConfig()
+	}
 	if h.lintTimer != nil {
 		h.lintTimer.Stop()
 		h.lintTimer = nil
 	}
+	for _, cancel := range h.pendingRequests {
+		cancel()
+	}
 	close(h.request)
 }
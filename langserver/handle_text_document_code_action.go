@@ -5,16 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *langHandler) handleTextDocumentCodeAction(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+func (h *langHandler) handleTextDocumentCodeAction(ctx context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
 	if req.Params == nil {
 		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
 	}
@@ -24,7 +22,7 @@ func (h *langHandler) handleTextDocumentCodeAction(_ context.Context, _ *jsonrpc
 		return nil, err
 	}
 
-	return h.codeAction(params.TextDocument.URI, &params)
+	return h.codeAction(ctx, params.TextDocument.URI, &params)
 }
 
 func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error) {
@@ -54,7 +52,7 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(DocumentURI(tok[2]), f.LanguageID); len(cfgs) > 0 {
 	loop_lang:
 		for _, cfg := range cfgs {
 			for _, v := range cfg.Commands {
@@ -66,7 +64,7 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 		}
 	}
 	if command == nil {
-		if cfgs, ok := h.configs[wildcard]; ok {
+		if cfgs := h.languageConfigs(DocumentURI(tok[2]), wildcard); len(cfgs) > 0 {
 		loop_wild:
 			for _, cfg := range cfgs {
 				for _, v := range cfg.Commands {
@@ -78,7 +76,10 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 			}
 		}
 		if command == nil {
-			for _, v := range h.commands {
+			h.mu.Lock()
+			commands := append(append([]Command{}, h.commands...), h.projectCommands(DocumentURI(tok[2]))...)
+			h.mu.Unlock()
+			for _, v := range commands {
 				if tok[1] == v.Command {
 					command = &v
 					break
@@ -90,64 +91,49 @@ func (h *langHandler) executeCommand(params *ExecuteCommandParams) (any, error)
 		}
 	}
 
-	var cmd *exec.Cmd
 	var args []string
 	var output string
 	if !strings.HasPrefix(command.Command, ":") {
-		if runtime.GOOS == "windows" {
-			args = []string{"/c", replaceCommandInputFilename(command.Command, fname, h.rootPath)}
-			for _, v := range command.Arguments {
-				arg := fmt.Sprint(v)
-				tmp := replaceCommandInputFilename(arg, fname, h.rootPath)
-				if tmp != arg && fname == "" {
-					h.logger.Println("invalid uri")
-					return nil, fmt.Errorf("invalid uri: %v", uri)
-				}
-				arg = tmp
-				args = append(args, arg)
+		for _, v := range command.Arguments {
+			arg := fmt.Sprint(v)
+			tmp := replaceCommandInputFilename(arg, fname, h.rootPath)
+			if tmp != arg && fname == "" {
+				h.logger.Println("invalid uri")
+				return nil, fmt.Errorf("invalid uri: %v", uri)
 			}
-			cmd = exec.Command("cmd", args...)
-		} else {
-			args = []string{"-c", replaceCommandInputFilename(command.Command, fname, h.rootPath)}
+			arg = tmp
+			args = append(args, arg)
+		}
+		c := shellCommand{
+			Command: replaceCommandInputFilename(command.Command, fname, h.rootPath),
+			Args:    args,
+			Dir:     h.rootPath,
+			Env:     os.Environ(),
+
+			Tool:     command.Command,
+			Priority: priorityInteractive,
+		}
+		if len(command.Argv) > 0 {
+			argv := append([]string{}, command.Argv...)
 			for _, v := range command.Arguments {
-				arg := fmt.Sprint(v)
-				tmp := replaceCommandInputFilename(arg, fname, h.rootPath)
-				if tmp != arg && fname == "" {
-					h.logger.Println("invalid uri")
-					return nil, fmt.Errorf("invalid uri: %v", uri)
-				}
-				arg = tmp
-				args = append(args, arg)
+				argv = append(argv, fmt.Sprint(v))
 			}
-			cmd = exec.Command("sh", args...)
+			c.Argv = replaceArgvInputFilename(argv, fname, h.rootPath)
 		}
-		cmd.Dir = h.rootPath
-		cmd.Env = os.Environ()
-		b, err := cmd.CombinedOutput()
+		b, err := h.output(context.Background(), c)
 		if err != nil {
 			return nil, err
 		}
 		if h.loglevel >= 3 {
-			h.logger.Print(strings.Join(cmd.Args, " ")+":", string(b))
+			h.logger.Print(strings.Join(append([]string{c.Command}, args...), " ")+":", string(b))
 		}
 		output = string(b)
 	} else {
 		if command.Command == ":reload-config" {
-			config, err := LoadConfig(h.filename)
-			if err != nil {
+			if err := h.reloadConfig(); err != nil {
 				return nil, err
 			}
-			h.mu.Lock()
-			h.commands = *config.Commands
-			h.configs = *config.Languages
-			h.rootMarkers = *config.RootMarkers
-			h.triggerChars = config.TriggerChars
-			h.loglevel = config.LogLevel
-			h.lintDebounce = time.Duration(config.LintDebounce)
-			h.formatDebounce = time.Duration(config.FormatDebounce)
-			h.mu.Unlock()
 		}
-		h.logMessage(LogInfo, "Reloaded configuration file")
 		output = "OK"
 	}
 
@@ -177,24 +163,38 @@ func filterCommands(uri DocumentURI, commands []Command) []Command {
 	return results
 }
 
-func (h *langHandler) codeAction(uri DocumentURI, _ *CodeActionParams) ([]Command, error) {
+func (h *langHandler) codeAction(ctx context.Context, uri DocumentURI, params *CodeActionParams) ([]any, error) {
 	f, ok := h.files[uri]
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
 	}
 
+	actions := []any{}
+	for _, action := range h.quickFixActions(uri, params) {
+		actions = append(actions, action)
+	}
+	for _, action := range h.fixCommandActions(ctx, uri, params) {
+		actions = append(actions, action)
+	}
+
 	commands := []Command{}
 	commands = append(commands, filterCommands(uri, h.commands)...)
+	h.mu.Lock()
+	commands = append(commands, filterCommands(uri, h.projectCommands(uri))...)
+	h.mu.Unlock()
 
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(uri, f.LanguageID); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			commands = append(commands, filterCommands(uri, cfg.Commands)...)
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
+	if cfgs := h.languageConfigs(uri, wildcard); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			commands = append(commands, filterCommands(uri, cfg.Commands)...)
 		}
 	}
-	return commands, nil
+	for _, command := range commands {
+		actions = append(actions, command)
+	}
+	return actions, nil
 }
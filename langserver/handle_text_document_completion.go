@@ -7,10 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -45,14 +45,14 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 	}
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(uri, f.LanguageID); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.CompletionCommand != "" {
 				configs = append(configs, cfg)
 			}
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
+	if cfgs := h.languageConfigs(uri, wildcard); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.CompletionCommand != "" {
 				configs = append(configs, cfg)
@@ -74,26 +74,40 @@ func (h *langHandler) completion(uri DocumentURI, params *CompletionParams) ([]C
 
 		command := config.CompletionCommand
 
+		// The tool counts the column of ${POSITION} in its own unit.
+		unit, err := columnUnitEncoding(config.LintColumnUnit)
+		if err != nil {
+			return nil, err
+		}
+		line := params.Position.Line
+		position := fmt.Sprintf("%d:%d", line, convertCharacter(lineAt(f.Text, line), params.Position.Character, h.encoding(), unit))
 		if strings.Contains(command, "${POSITION}") {
-			command = strings.Replace(command, "${POSITION}", fmt.Sprintf("%d:%d", params.TextDocumentPositionParams.Position.Line, params.Position.Character), -1)
+			command = strings.Replace(command, "${POSITION}", position, -1)
 		}
 		if !config.CompletionStdin && !strings.Contains(command, "${INPUT}") {
 			command = command + " ${INPUT}"
 		}
 		command = replaceCommandInputFilename(command, fname, h.rootPath)
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		c := shellCommand{
+			Command: command,
+			Dir:     h.findRootPath(fname, config),
+			Env:     append(os.Environ(), config.Env...),
+			Timeout: time.Duration(config.CompletionTimeout),
+
+			Tool:        config.CompletionCommand,
+			MaxParallel: config.MaxParallel,
+			Priority:    priorityInteractive,
+		}
+		if len(config.CompletionArgv) > 0 {
+			argv := replaceArgv(config.CompletionArgv, "${POSITION}", position)
+			c.Argv = replaceArgvInputFilename(withInput(argv, config.CompletionStdin), fname, h.rootPath)
+			command = strings.Join(c.Argv, " ")
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.CompletionStdin {
-			cmd.Stdin = strings.NewReader(f.Text)
+			c.Stdin = strings.NewReader(f.Text)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.output(context.Background(), c)
 		if err != nil {
 			h.logger.Printf("completion command failed: %v", err)
 			return nil, fmt.Errorf("completion command failed: %v: %v", err, string(b))
@@ -133,17 +133,19 @@ func (h *langHandler) definition(uri DocumentURI, params *DocumentDefinitionPara
 	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
 		return nil, fmt.Errorf("invalid position: %v", params.Position)
 	}
-	chars := utf16.Encode([]rune(lines[params.Position.Line]))
-	if params.Position.Character < 0 || params.Position.Character > len(chars) {
+	line := lines[params.Position.Line]
+	if params.Position.Character < 0 || params.Position.Character > encodedLen(line, h.encoding()) {
 		return nil, fmt.Errorf("invalid position: %v", params.Position)
 	}
+	chars := utf16.Encode([]rune(line))
+	character := convertCharacter(line, params.Position.Character, h.encoding(), PositionEncodingUTF16)
 	prevPos := 0
 	currPos := -1
 	prevCls := unicodeclass.Invalid
 	for i, char := range chars {
 		currCls := unicodeclass.Is(rune(char))
 		if currCls != prevCls {
-			if i <= params.Position.Character {
+			if i <= character {
 				prevPos = i
 			} else {
 				if char == '_' {
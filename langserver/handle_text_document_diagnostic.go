@@ -0,0 +1,210 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// codeServerCancelled is the LSP error code telling the client that the
+// server cancelled a request, e.g. because the document changed meanwhile.
+const codeServerCancelled = -32802
+
+// diagnosticResult is the last diagnostic report handed out for a document
+// through pull diagnostics.
+type diagnosticResult struct {
+	ID   string
+	Hash [sha256.Size]byte
+}
+
+func (h *langHandler) handleTextDocumentDiagnostic(ctx context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DocumentDiagnosticParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	return h.documentDiagnostic(ctx, &params)
+}
+
+func (h *langHandler) documentDiagnostic(ctx context.Context, params *DocumentDiagnosticParams) (*DocumentDiagnosticReport, error) {
+	uri := params.TextDocument.URI
+	uriToDiagnostics, err := h.pullLint(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	report := h.diagnosticReport(uri, uriToDiagnostics[uri], params.PreviousResultID)
+	for diagURI, diagnostics := range uriToDiagnostics {
+		if diagURI == uri {
+			continue
+		}
+		if report.RelatedDocuments == nil {
+			report.RelatedDocuments = make(map[DocumentURI]DocumentDiagnosticReport)
+		}
+		report.RelatedDocuments[diagURI] = h.diagnosticReport(diagURI, diagnostics, "")
+	}
+	return &report, nil
+}
+
+// pullLint runs the linters for uri as the last notification about the
+// document would have in push mode, once it was left alone for
+// lint-debounce. It fails with ServerCancelled when the document is edited
+// while the linters run, which stops them, so that stale diagnostics are
+// never reported for the new text.
+func (h *langHandler) pullLint(ctx context.Context, uri DocumentURI) (map[DocumentURI][]Diagnostic, error) {
+	if err := h.pullDebounce(ctx, uri); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	f, ok := h.files[uri]
+	if !ok {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("document not found: %v", uri)
+	}
+	version := f.Version
+	event, ok := h.pullEvents[uri]
+	if !ok {
+		event = eventTypeSave
+	}
+	h.mu.Unlock()
+
+	lintCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go h.cancelOnEdit(lintCtx, cancel, uri, version)
+
+	uriToDiagnostics, err := h.lint(lintCtx, uri, event)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uriToDiagnostics == nil {
+		return nil, cancelledError()
+	}
+
+	h.mu.Lock()
+	f, ok = h.files[uri]
+	changed := !ok || f.Version != version
+	h.mu.Unlock()
+	if changed {
+		return nil, cancelledError()
+	}
+	return uriToDiagnostics, nil
+}
+
+// pullDebounce waits until the document uri was last changed lint-debounce
+// ago.
+func (h *langHandler) pullDebounce(ctx context.Context, uri DocumentURI) error {
+	for {
+		h.mu.Lock()
+		wait := h.lintDebounce - time.Since(h.pullChanged[uri])
+		h.mu.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// cancelOnEdit calls cancel once the document uri is no longer at version,
+// unless ctx is done first.
+func (h *langHandler) cancelOnEdit(ctx context.Context, cancel context.CancelFunc, uri DocumentURI, version int) {
+	for {
+		h.mu.Lock()
+		changed := h.workspaceChangeLocked()
+		f, ok := h.files[uri]
+		edited := !ok || f.Version != version
+		h.mu.Unlock()
+		if edited {
+			cancel()
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func cancelledError() error {
+	err := &jsonrpc2.Error{Code: codeServerCancelled, Message: "document changed while linting"}
+	err.SetError(DiagnosticServerCancellationData{RetriggerRequest: true})
+	return err
+}
+
+// diagnosticReport builds the report for diagnostics, which is unchanged if
+// they are identical to the ones last reported under previousResultID.
+func (h *langHandler) diagnosticReport(uri DocumentURI, diagnostics []Diagnostic, previousResultID string) DocumentDiagnosticReport {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	b, _ := json.Marshal(diagnostics)
+	hash := sha256.Sum256(b)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.diagnosticResults == nil {
+		h.diagnosticResults = make(map[DocumentURI]diagnosticResult)
+	}
+	last, ok := h.diagnosticResults[uri]
+	if !ok || last.Hash != hash {
+		h.lastResultID++
+		last = diagnosticResult{ID: strconv.Itoa(h.lastResultID), Hash: hash}
+		h.diagnosticResults[uri] = last
+	}
+	if previousResultID != "" && previousResultID == last.ID {
+		return DocumentDiagnosticReport{Kind: UnchangedReport, ResultID: last.ID}
+	}
+	return DocumentDiagnosticReport{Kind: FullReport, ResultID: last.ID, Items: diagnostics}
+}
+
+// MarshalJSON leaves the items out of unchanged reports, which have none.
+func (r DocumentDiagnosticReport) MarshalJSON() ([]byte, error) {
+	type plain DocumentDiagnosticReport
+	if r.Kind != UnchangedReport {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		Kind             DocumentDiagnosticReportKind             `json:"kind"`
+		ResultID         string                                   `json:"resultId"`
+		RelatedDocuments map[DocumentURI]DocumentDiagnosticReport `json:"relatedDocuments,omitempty"`
+	}{r.Kind, r.ResultID, r.RelatedDocuments})
+}
+
+// MarshalJSON adds the document to its report, since the MarshalJSON of the
+// embedded report would otherwise leave it out.
+func (r WorkspaceDocumentDiagnosticReport) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(r.DocumentDiagnosticReport)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	if fields["uri"], err = json.Marshal(r.URI); err != nil {
+		return nil, err
+	}
+	if fields["version"], err = json.Marshal(r.Version); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,282 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDocumentDiagnosticUnchanged(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo ` + file + `:2:No it is normal!`,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "scriptencoding utf-8\nabnormal!\n",
+				Version:    1,
+			},
+		},
+	}
+
+	params := &DocumentDiagnosticParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	report, err := h.documentDiagnostic(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != FullReport || len(report.Items) != 1 {
+		t.Fatalf("report should be full with one item but got: %+v", report)
+	}
+	if report.ResultID == "" {
+		t.Fatal("report should have a result id")
+	}
+
+	params.PreviousResultID = report.ResultID
+	again, err := h.documentDiagnostic(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Kind != UnchangedReport || again.ResultID != report.ResultID {
+		t.Fatalf("report should be unchanged with result id %q but got: %+v", report.ResultID, again)
+	}
+
+	h.configs["vim"][0].LintCommand = `echo ` + file + `:1:Now the first line`
+	changed, err := h.documentDiagnostic(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Kind != FullReport || changed.ResultID == report.ResultID {
+		t.Fatalf("report should be full with a new result id but got: %+v", changed)
+	}
+}
+
+func TestWorkspaceDiagnostic(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo ` + file + `:2:No it is normal!`,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "scriptencoding utf-8\nabnormal!\n",
+				Version:    3,
+			},
+		},
+	}
+
+	report, err := h.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 {
+		t.Fatalf("report should have one document but got: %+v", report)
+	}
+	item := report.Items[0]
+	if item.URI != uri || item.Version == nil || *item.Version != 3 || len(item.Items) != 1 {
+		t.Fatalf("unexpected document report: %+v", item)
+	}
+}
+
+func TestWorkspaceDiagnosticHeldUntilChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cat is not available")
+	}
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	runs := filepath.Join(base, "runs")
+	message := filepath.Join(base, "message")
+	if err := os.WriteFile(message, []byte(file+":1:first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &langHandler{
+		logger:          log.New(io.Discard, "", 0),
+		rootPath:        base,
+		pullDiagnostics: true,
+		pullEvents:      map[DocumentURI]eventType{},
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo >> ` + runs + `; cat ` + message,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\n", Version: 1},
+		},
+	}
+	countRuns := func() int {
+		b, _ := os.ReadFile(runs)
+		return len(b)
+	}
+
+	report, err := h.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Kind != FullReport {
+		t.Fatalf("the first report should be full but got: %+v", report)
+	}
+	previous := &WorkspaceDiagnosticParams{
+		PreviousResultIDs: []PreviousResultID{{URI: uri, Value: report.Items[0].ResultID}},
+	}
+
+	done := make(chan *WorkspaceDiagnosticReport)
+	go func() {
+		report, err := h.workspaceDiagnostic(context.Background(), previous)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- report
+	}()
+	select {
+	case report := <-done:
+		t.Fatalf("the request should be held until something changes but got: %+v", report)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if got := countRuns(); got != 1 {
+		t.Fatalf("the linter should not run again until something changes but ran %d times", got)
+	}
+
+	if err := os.WriteFile(message, []byte(file+":1:second\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.lintRequest(uri, eventTypeChange)
+	select {
+	case report := <-done:
+		if len(report.Items) != 1 || report.Items[0].Kind != FullReport || report.Items[0].Items[0].Message != "second" {
+			t.Fatalf("the changed diagnostics should be reported but got: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request should be answered once the diagnostics change")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	h.mu.Lock()
+	previous.PreviousResultIDs[0].Value = h.diagnosticResults[uri].ID
+	h.mu.Unlock()
+	if _, err := h.workspaceDiagnostic(ctx, previous); err == nil {
+		t.Fatal("a cancelled request should fail")
+	}
+}
+
+func TestDocumentDiagnosticDebounce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	base := t.TempDir()
+	uri := toURI(filepath.Join(base, "foo"))
+	debounce := 200 * time.Millisecond
+
+	h := &langHandler{
+		logger:          log.New(io.Discard, "", 0),
+		rootPath:        base,
+		lintDebounce:    debounce,
+		pullDiagnostics: true,
+		pullEvents:      map[DocumentURI]eventType{},
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo stdin:1:msg`,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: "a\n", Version: 1},
+		},
+	}
+
+	h.lintRequest(uri, eventTypeChange)
+	start := time.Now()
+	report, err := h.documentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < debounce/2 {
+		t.Fatalf("linters should run once the document was left alone for lint-debounce but ran after %v", elapsed)
+	}
+	if len(report.Items) != 1 {
+		t.Fatalf("report should have one diagnostic but got: %+v", report)
+	}
+
+	// Documents not changed lately are linted at once.
+	start = time.Now()
+	if _, err := h.documentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= debounce {
+		t.Fatalf("linters should run at once but ran after %v", elapsed)
+	}
+}
+
+func TestDiagnosticReportJSON(t *testing.T) {
+	version := 2
+	tests := []struct {
+		report any
+		want   string
+	}{
+		{DocumentDiagnosticReport{Kind: UnchangedReport, ResultID: "1"}, `{"kind":"unchanged","resultId":"1"}`},
+		{DocumentDiagnosticReport{Kind: FullReport, ResultID: "2", Items: []Diagnostic{}}, `{"kind":"full","resultId":"2","items":[]}`},
+		{
+			WorkspaceDocumentDiagnosticReport{
+				DocumentDiagnosticReport: DocumentDiagnosticReport{Kind: UnchangedReport, ResultID: "3"},
+				URI:                      "file:///foo",
+				Version:                  &version,
+			},
+			`{"kind":"unchanged","resultId":"3","uri":"file:///foo","version":2}`,
+		},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(test.report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.want {
+			t.Errorf("report should be marshaled to %s but got: %s", test.want, b)
+		}
+	}
+}
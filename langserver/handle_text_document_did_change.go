@@ -3,6 +3,7 @@ package langserver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -18,9 +19,20 @@ func (h *langHandler) handleTextDocumentDidChange(_ context.Context, _ *jsonrpc2
 	}
 
 	if len(params.ContentChanges) > 0 {
-		// The server requests full sync, so every change carries the whole
-		// text and the last one wins.
-		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		h.mu.Lock()
+		f, ok := h.files[params.TextDocument.URI]
+		if !ok {
+			h.mu.Unlock()
+			return nil, fmt.Errorf("document not found: %v", params.TextDocument.URI)
+		}
+		text := f.Text
+		h.mu.Unlock()
+
+		// Changes are applied in order; one without a range replaces the
+		// whole text.
+		for _, change := range params.ContentChanges {
+			text = applyContentChange(text, change, h.encoding())
+		}
 		if err := h.updateFile(params.TextDocument.URI, text, &params.TextDocument.Version, eventTypeChange); err != nil {
 			return nil, err
 		}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"math/rand"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf16"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -52,3 +55,135 @@ func TestDidChangeMultipleContentChanges(t *testing.T) {
 		t.Fatalf("version should be %v but got: %v", 2, h.files[uri].Version)
 	}
 }
+
+func TestDidChangeIncremental(t *testing.T) {
+	uri := DocumentURI("file:///foo")
+	h := &langHandler{
+		logger:       log.New(log.Writer(), "", log.LstdFlags),
+		lintDebounce: time.Millisecond,
+		request:      make(chan lintRequest, 1),
+		pendingLints: make(map[DocumentURI]eventType),
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "let s = '日本語'\r\necho s\r\n",
+			},
+		},
+	}
+
+	params, err := json.Marshal(DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: TextDocumentIdentifier{URI: uri},
+			Version:                2,
+		},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			// Replace "本" which is one UTF-16 code unit but three bytes.
+			{Range: &Range{Start: Position{Line: 0, Character: 10}, End: Position{Line: 0, Character: 11}}, Text: "🍣"},
+			// The previous change moved "語" by one code unit.
+			{Range: &Range{Start: Position{Line: 0, Character: 12}, End: Position{Line: 0, Character: 13}}, Text: ""},
+			{Range: &Range{Start: Position{Line: 1, Character: 6}, End: Position{Line: 1, Character: 6}}, Text: "\r\nfinish"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := json.RawMessage(params)
+
+	req := &jsonrpc2.Request{Params: &raw}
+	if _, err := h.handleTextDocumentDidChange(context.Background(), nil, req); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "let s = '日🍣'\r\necho s\r\nfinish\r\n"
+	if h.files[uri].Text != want {
+		t.Fatalf("text should be %q but got: %q", want, h.files[uri].Text)
+	}
+}
+
+// utf16Document is a reference implementation of an LSP text document which
+// stores the text as UTF-16 code units like the clients do.
+type utf16Document []uint16
+
+func (d utf16Document) offset(pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		for offset < len(d) && d[offset] != '\n' && d[offset] != '\r' {
+			offset++
+		}
+		if offset == len(d) {
+			return offset
+		}
+		if d[offset] == '\r' && offset+1 < len(d) && d[offset+1] == '\n' {
+			offset++
+		}
+		offset++
+	}
+	for char := 0; char < pos.Character && offset < len(d) && d[offset] != '\n' && d[offset] != '\r'; char++ {
+		offset++
+	}
+	return offset
+}
+
+// randomPosition returns a position that does not split a surrogate pair.
+func (d utf16Document) randomPosition(r *rand.Rand) Position {
+	var lines [][]uint16
+	start := 0
+	for i := 0; i < len(d); i++ {
+		if d[i] == '\r' && i+1 < len(d) && d[i+1] == '\n' {
+			lines = append(lines, d[start:i])
+			i++
+			start = i + 1
+		} else if d[i] == '\n' || d[i] == '\r' {
+			lines = append(lines, d[start:i])
+			start = i + 1
+		}
+	}
+	lines = append(lines, d[start:])
+	line := r.Intn(len(lines))
+	var boundaries []int
+	for i := 0; i <= len(lines[line]); i++ {
+		if i == len(lines[line]) || !utf16.IsSurrogate(rune(lines[line][i])) || lines[line][i] < 0xdc00 {
+			boundaries = append(boundaries, i)
+		}
+	}
+	return Position{Line: line, Character: boundaries[r.Intn(len(boundaries))]}
+}
+
+func TestApplyContentChangeRandom(t *testing.T) {
+	pieces := []string{"a", "bc", " ", "\n", "\r\n", "日本", "🍣", "é", "\t", "xyz\n", "\r"}
+	r := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		var b strings.Builder
+		for i := r.Intn(8); i > 0; i-- {
+			b.WriteString(pieces[r.Intn(len(pieces))])
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 200; i++ {
+		text := randomText()
+		doc := utf16Document(utf16.Encode([]rune(text)))
+		for j := 0; j < 20; j++ {
+			start, end := doc.randomPosition(r), doc.randomPosition(r)
+			if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+				start, end = end, start
+			}
+			newText := randomText()
+			// Do not split "\r\n" as clients never do.
+			so, eo := doc.offset(start), doc.offset(end)
+			if (so > 0 && so < len(doc) && doc[so-1] == '\r' && doc[so] == '\n') ||
+				(eo > 0 && eo < len(doc) && doc[eo-1] == '\r' && doc[eo] == '\n') {
+				continue
+			}
+			doc = append(append(append(utf16Document{}, doc[:so]...), utf16.Encode([]rune(newText))...), doc[eo:]...)
+
+			text = applyContentChange(text, TextDocumentContentChangeEvent{
+				Range: &Range{Start: start, End: end},
+				Text:  newText,
+			}, PositionEncodingUTF16)
+			if want := string(utf16.Decode(doc)); text != want {
+				t.Fatalf("replacing %v-%v with %q should produce %q but got: %q", start, end, newText, want, text)
+			}
+		}
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -61,7 +62,35 @@ func (h *langHandler) rangeFormatRequest(uri DocumentURI, rng Range, opt Formatt
 	return h.rangeFormatting(uri, rng, opt)
 }
 
+// formatCommandLine returns the command formatting fname with config, before
+// the formatting options are expanded, and its argv when it is run without a
+// shell.
+func formatCommandLine(config Language, fname, rootPath string) (string, []string) {
+	tempfile := config.FormatTempfile || config.FormatOutput == formatOutputInPlaceTempfile
+	if len(config.FormatArgv) > 0 {
+		argv := withInput(config.FormatArgv, config.FormatStdin)
+		if tempfile {
+			argv = tempfileArgv(config.FormatArgv)
+		}
+		return strings.Join(argv, " "), replaceArgvInputFilename(argv, fname, rootPath)
+	}
+	command := config.FormatCommand
+	if tempfile {
+		command = tempfileCommand(command)
+	} else if !config.FormatStdin && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	return replaceCommandInputFilename(command, fname, rootPath), nil
+}
+
 func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options FormattingOptions) ([]TextEdit, error) {
+	return h.formatDocument(context.Background(), uri, rng, options, nil)
+}
+
+// formatDocument formats rng of the document uri with its formatters which
+// use accepts, or all of them when use is nil. The formatters are killed
+// once ctx is done.
+func (h *langHandler) formatDocument(ctx context.Context, uri DocumentURI, rng Range, options FormattingOptions, use func(Language) bool) ([]TextEdit, error) {
 	f, ok := h.files[uri]
 	if !ok {
 		return nil, fmt.Errorf("document not found: %v", uri)
@@ -77,19 +106,19 @@ func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options Format
 	}
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(uri, f.LanguageID); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
-			if cfg.FormatCommand != "" {
-				if dir := matchRootPath(fname, cfg.RootMarkers); dir == "" && cfg.RequireMarker {
+			if cfg.FormatCommand != "" && (use == nil || use(cfg)) {
+				if requireMarkerSkipReason(cfg, fname) != "" {
 					continue
 				}
 				configs = append(configs, cfg)
 			}
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
+	if cfgs := h.languageConfigs(uri, wildcard); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
-			if cfg.FormatCommand != "" {
+			if cfg.FormatCommand != "" && (use == nil || use(cfg)) {
 				configs = append(configs, cfg)
 			}
 		}
@@ -102,116 +131,61 @@ func (h *langHandler) rangeFormatting(uri DocumentURI, rng Range, options Format
 		return nil, nil
 	}
 
+	strategy := formatStrategy(configs)
+	switch strategy {
+	case "pipeline", "first-success":
+	case "priority":
+		sort.SliceStable(configs, func(i, j int) bool {
+			return configs[i].FormatPriority > configs[j].FormatPriority
+		})
+	default:
+		return nil, fmt.Errorf("invalid format-strategy: %v", strategy)
+	}
+
 	originalText := f.Text
 	text := originalText
 	formatted := false
 
-Configs:
-	for _, config := range configs {
-		if config.FormatCommand == "" {
-			continue
+	// steps tells how each formatter went, which is shown when any failed.
+	var steps []string
+	failed := false
+	// edits are those given by the only formatter which succeeded, if any.
+	var edits []TextEdit
+	for i, config := range configs {
+		name := checkToolName(config, i)
+		out, stepEdits, err := h.formatRangeStep(ctx, config, fname, text, rng, options)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-
-		// File options
-		command := config.FormatCommand
-		if !config.FormatStdin && !strings.Contains(command, "${INPUT}") {
-			command = command + " ${INPUT}"
-		}
-		command = replaceCommandInputFilename(command, fname, h.rootPath)
-
-		// Formatting Options
-		for placeholder, value := range options {
-			// {--flag:placeholder} => --flag <value>
-			// {--flag=placeholder} => --flag=<value>
-			// {--flag:!placeholder} => --flag if value is false
-			re, err := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):%s}`, placeholder))
-			re2, err2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=%s}`, placeholder))
-			nre, nerr := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):!%s}`, placeholder))
-			nre2, nerr2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=!%s}`, placeholder))
-			if err != nil || err2 != nil || nerr != nil || nerr2 != nil {
-				h.logger.Println(command+":", err)
-				continue Configs
-			}
-
-			switch v := value.(type) {
-			default:
-				command = re.ReplaceAllString(command, fmt.Sprintf("$1 %v", v))
-				command = re2.ReplaceAllString(command, fmt.Sprintf("$1=%v", v))
-			case bool:
-				const FLAG = "$1"
-				if v {
-					command = re.ReplaceAllString(command, FLAG)
-					command = re2.ReplaceAllString(command, FLAG)
-				} else {
-					command = nre.ReplaceAllString(command, FLAG)
-					command = nre2.ReplaceAllString(command, FLAG)
-				}
-			}
-		}
-
-		// Range Options
-		if rng.Start.Line != -1 {
-			charStart := convertRowColToIndex(text, rng.Start.Line, rng.Start.Character)
-			charEnd := convertRowColToIndex(text, rng.End.Line, rng.End.Character)
-
-			rangeOptions := map[string]int{
-				"charStart": charStart,
-				"charEnd":   charEnd,
-				"rowStart":  rng.Start.Line,
-				"colStart":  rng.Start.Character,
-				"rowEnd":    rng.End.Line,
-				"colEnd":    rng.End.Character,
-			}
-
-			for placeholder, value := range rangeOptions {
-				// {--flag:placeholder} => --flag <value>
-				// {--flag=placeholder} => --flag=<value>
-				re, err := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):%s}`, placeholder))
-				re2, err2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=%s}`, placeholder))
-				if err != nil || err2 != nil {
-					h.logger.Println(command+":", err)
-					continue Configs
-				}
-
-				command = re.ReplaceAllString(command, fmt.Sprintf("$1 %d", value))
-				command = re2.ReplaceAllString(command, fmt.Sprintf("$1=%d", value))
+		if err != nil {
+			steps = append(steps, fmt.Sprintf("%s failed: %v", name, err))
+			failed = true
+			if strategy == "pipeline" && config.FormatStopOnError {
+				h.showMessage(LogWarning, "format: "+strings.Join(steps, ", "))
+				return nil, fmt.Errorf("format: %s failed: %v", name, err)
 			}
+			continue
 		}
-
-		// remove unfilled placeholders
-		re := regexp.MustCompile(`\${[^}]*}`)
-		command = re.ReplaceAllString(command, "")
-
-		// Execute the command
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
+		steps = append(steps, name+" succeeded")
+		if formatted {
+			edits = nil
 		} else {
-			cmd = exec.Command("sh", "-c", command)
-		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
-		if config.FormatStdin {
-			cmd.Stdin = strings.NewReader(text)
-		}
-		var buf bytes.Buffer
-		cmd.Stderr = &buf
-		b, err := cmd.Output()
-
-		// Most format tools exit with zero status code when formatting is successful.
-		// Some do not.
-		// To handle a formatter that exits with non-zero value, use format-ignore-exit-code.
-		if err != nil && !config.FormatIgnoreExitCode {
-			h.logger.Println(command+":", buf.String())
-			continue
+			edits = stepEdits
 		}
-
 		formatted = true
-
-		if h.loglevel >= 3 {
-			h.logger.Println(command+":", string(b))
+		text = out
+		if strategy != "pipeline" {
+			break
+		}
+	}
+	if failed {
+		h.showMessage(LogWarning, "format: "+strings.Join(steps, ", "))
+	}
+	if edits != nil {
+		if len(edits) == 0 {
+			return nil, nil
 		}
-		text = strings.Replace(string(b), "\r", "", -1)
+		return edits, nil
 	}
 
 	// The formatter output was normalized to LF above; when the original
@@ -228,8 +202,371 @@ Configs:
 		if h.loglevel >= 3 {
 			h.logger.Println("format succeeded")
 		}
-		return ComputeEdits(uri, originalText, text), nil
+		return computeEdits(originalText, text, h.encoding()), nil
 	}
 
 	return nil, fmt.Errorf("format for LanguageID not supported: %v", f.LanguageID)
 }
+
+// formatStrategy returns how the formatters configs are run: the first
+// format-strategy set by them, or "pipeline".
+func formatStrategy(configs []Language) string {
+	for _, config := range configs {
+		if config.FormatStrategy != "" {
+			return config.FormatStrategy
+		}
+	}
+	return "pipeline"
+}
+
+// Modes of format-range-emulation.
+const (
+	formatRangeHunks     = "hunks"
+	formatRangeSelection = "selection"
+)
+
+// formatRangeStep is formatStep, which emulates range formatting for
+// formatters with format-range-emulation: with "hunks", the whole text is
+// formatted and only the changes touching the lines of rng are kept, and with
+// "selection", the lines of rng are formatted alone, without their common
+// indentation, which is put back.
+func (h *langHandler) formatRangeStep(ctx context.Context, config Language, fname, text string, rng Range, options FormattingOptions) (string, []TextEdit, error) {
+	whole := Range{Position{-1, -1}, Position{-1, -1}}
+	// The lines of rng are formatted, ignoring the end of a range ending at
+	// the start of a line.
+	endLine := rng.End.Line
+	if rng.End.Character == 0 && endLine > rng.Start.Line {
+		endLine--
+	}
+	lines := Range{Start: Position{Line: rng.Start.Line}, End: Position{Line: endLine + 1}}
+	switch {
+	case rng.Start.Line == -1 || config.FormatCanRange || config.FormatRangeEmulation == "":
+		return h.formatStep(ctx, config, fname, text, rng, options)
+	case config.FormatRangeEmulation == formatRangeHunks:
+		out, edits, err := h.formatStep(ctx, config, fname, text, whole, options)
+		if err != nil {
+			return "", nil, err
+		}
+		if edits == nil {
+			if strings.Contains(text, "\r\n") {
+				out = strings.Replace(out, "\n", "\r\n", -1)
+			}
+			edits = computeEdits(text, out, h.encoding())
+		}
+		kept := []TextEdit{}
+		for _, hunk := range editHunks(edits) {
+			if touchesRange(hunk.Range, lines) {
+				kept = append(kept, hunk.edits...)
+			}
+		}
+		formatted := applyTextEdits(text, kept, h.encoding())
+		return strings.Replace(formatted, "\r", "", -1), kept, nil
+	case config.FormatRangeEmulation == formatRangeSelection:
+		start := positionToOffset(text, lines.Start, h.encoding())
+		end := positionToOffset(text, lines.End, h.encoding())
+		selection := text[start:end]
+		indent := commonIndent(selection)
+		out, _, err := h.formatStep(ctx, config, fname, dedent(selection, indent), whole, options)
+		if err != nil {
+			return "", nil, err
+		}
+		out = reindent(out, indent)
+		if !strings.HasSuffix(selection, "\n") {
+			out = strings.TrimRight(out, "\n")
+		}
+		if strings.Contains(selection, "\r\n") {
+			out = strings.Replace(out, "\n", "\r\n", -1)
+		}
+		edit := TextEdit{
+			Range: Range{
+				Start: offsetToPosition(text, start, h.encoding()),
+				End:   offsetToPosition(text, end, h.encoding()),
+			},
+			NewText: out,
+		}
+		formatted := text[:start] + out + text[end:]
+		return strings.Replace(formatted, "\r", "", -1), []TextEdit{edit}, nil
+	}
+	return "", nil, fmt.Errorf("invalid format-range-emulation: %v", config.FormatRangeEmulation)
+}
+
+// editHunk is a group of edits touching each other, such as the deletion
+// and the insertion replacing lines.
+type editHunk struct {
+	Range Range
+	edits []TextEdit
+}
+
+// editHunks groups edits into hunks.
+func editHunks(edits []TextEdit) []editHunk {
+	edits = append([]TextEdit{}, edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return positionBefore(edits[i].Range.Start, edits[j].Range.Start)
+	})
+	var hunks []editHunk
+	for _, edit := range edits {
+		if n := len(hunks); n > 0 && !positionBefore(hunks[n-1].Range.End, edit.Range.Start) {
+			if positionBefore(hunks[n-1].Range.End, edit.Range.End) {
+				hunks[n-1].Range.End = edit.Range.End
+			}
+			hunks[n-1].edits = append(hunks[n-1].edits, edit)
+			continue
+		}
+		hunks = append(hunks, editHunk{Range: edit.Range, edits: []TextEdit{edit}})
+	}
+	return hunks
+}
+
+func positionBefore(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
+
+// touchesRange reports whether the edit range r changes text within rng,
+// or inserts text in it.
+func touchesRange(r, rng Range) bool {
+	if r.Start == r.End {
+		return !positionBefore(r.Start, rng.Start) && !positionBefore(rng.End, r.Start)
+	}
+	return positionBefore(r.Start, rng.End) && positionBefore(rng.Start, r.End)
+}
+
+// commonIndent returns the leading white space shared by the lines of s
+// which are not blank.
+func commonIndent(s string) string {
+	indent, first := "", true
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lead, false
+			continue
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
+// dedent removes indent from the lines of s which start with it.
+func dedent(s, indent string) string {
+	if indent == "" {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reindent puts indent back at the start of the lines of s which are not
+// blank.
+func reindent(s, indent string) string {
+	if indent == "" {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatStep runs the formatter config on text and returns the formatted
+// text, with LF line endings. Formatters whose format-output is made of
+// edits also return those edits against text.
+func (h *langHandler) formatStep(ctx context.Context, config Language, fname, text string, rng Range, options FormattingOptions) (string, []TextEdit, error) {
+	switch config.FormatOutput {
+	case "", formatOutputText, formatOutputDiff, formatOutputXMLReplacements, formatOutputInPlaceTempfile:
+	default:
+		return "", nil, fmt.Errorf("invalid format-output: %v", config.FormatOutput)
+	}
+
+	// File options
+	command, argv := formatCommandLine(config, fname, h.rootPath)
+
+	// Range Options
+	var rangeOptions map[string]int
+	if rng.Start.Line != -1 {
+		// charStart and charEnd are byte offsets as they always were;
+		// JavaScript tools such as prettier count UTF-16 code units instead.
+		enc := h.encoding()
+		rangeOptions = map[string]int{
+			"charStart":      convertRowColToIndex(text, rng.Start.Line, rng.Start.Character, enc, PositionEncodingUTF8),
+			"charEnd":        convertRowColToIndex(text, rng.End.Line, rng.End.Character, enc, PositionEncodingUTF8),
+			"charStartUTF16": convertRowColToIndex(text, rng.Start.Line, rng.Start.Character, enc, PositionEncodingUTF16),
+			"charEndUTF16":   convertRowColToIndex(text, rng.End.Line, rng.End.Character, enc, PositionEncodingUTF16),
+			"rowStart":       rng.Start.Line,
+			"colStart":       rng.Start.Character,
+			"rowEnd":         rng.End.Line,
+			"colEnd":         rng.End.Character,
+		}
+	}
+
+	var err error
+	if argv != nil {
+		argv, err = expandFormatArgv(argv, options, rangeOptions)
+		command = strings.Join(argv, " ")
+	} else {
+		command, err = expandFormatOptions(command, options, rangeOptions)
+		command = removeUnfilledPlaceholders(command)
+	}
+	if err != nil {
+		h.logger.Println(command+":", err)
+		return "", nil, err
+	}
+
+	// Execute the command
+	c := shellCommand{
+		Command: command,
+		Argv:    argv,
+		Dir:     h.findRootPath(fname, config),
+		Env:     append(os.Environ(), config.Env...),
+		Timeout: time.Duration(config.FormatTimeout),
+
+		Tool:        config.FormatCommand,
+		MaxParallel: config.MaxParallel,
+		Priority:    priorityInteractive,
+	}
+	if config.FormatStdin {
+		c.Stdin = strings.NewReader(text)
+	}
+	var b, stderr, inPlace []byte
+	var readErr error
+	if config.FormatTempfile || config.FormatOutput == formatOutputInPlaceTempfile {
+		b, stderr, err = withTempfile(c, fname, text, func(c shellCommand, tmp string) ([]byte, []byte, error) {
+			b, stderr, err := h.runCommand(ctx, c)
+			if config.FormatOutput == formatOutputInPlaceTempfile {
+				inPlace, readErr = os.ReadFile(tmp)
+			}
+			return b, stderr, err
+		})
+	} else {
+		b, stderr, err = h.runCommand(ctx, c)
+	}
+	if errors.Is(err, errCommandTimeout) || errors.Is(err, errCommandOutputLimit) {
+		return "", nil, err
+	}
+
+	// Most format tools exit with zero status code when formatting is successful.
+	// Some do not.
+	// To handle a formatter that exits with non-zero value, use format-ignore-exit-code.
+	// Those printing diffs commonly exit with 1 when there are differences.
+	if err != nil && !config.FormatIgnoreExitCode && (config.FormatOutput != formatOutputDiff || len(bytes.TrimSpace(b)) == 0) {
+		h.logger.Println(command+":", string(stderr))
+		return "", nil, err
+	}
+
+	if h.loglevel >= 3 {
+		h.logger.Println(command+":", string(b))
+	}
+	switch config.FormatOutput {
+	case formatOutputDiff, formatOutputXMLReplacements:
+		edits, err := formatOutputEdits(config.FormatOutput, b, text, fname, h.encoding())
+		if err != nil {
+			return "", nil, err
+		}
+		formatted := applyTextEdits(text, edits, h.encoding())
+		return strings.Replace(formatted, "\r", "", -1), edits, nil
+	case formatOutputInPlaceTempfile:
+		if readErr != nil {
+			return "", nil, readErr
+		}
+		b = inPlace
+	}
+	return strings.Replace(string(b), "\r", "", -1), nil, nil
+}
+
+var (
+	unfilledPlaceholder  = regexp.MustCompile(`\${[^}]*}`)
+	flagValuePlaceholder = regexp.MustCompile(`^\${[^:|^}]+:!?[^}]+}$`)
+)
+
+// removeUnfilledPlaceholders removes the placeholders left in s, except
+// ${TEMPFILE} which is filled when the command is run.
+func removeUnfilledPlaceholders(s string) string {
+	return unfilledPlaceholder.ReplaceAllStringFunc(s, func(p string) string {
+		if p == "${TEMPFILE}" {
+			return p
+		}
+		return ""
+	})
+}
+
+// expandFormatOptions fills the placeholders of formatting and range options
+// in command.
+func expandFormatOptions(command string, options FormattingOptions, rangeOptions map[string]int) (string, error) {
+	// Formatting Options
+	for placeholder, value := range options {
+		// {--flag:placeholder} => --flag <value>
+		// {--flag=placeholder} => --flag=<value>
+		// {--flag:!placeholder} => --flag if value is false
+		re, err := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):%s}`, placeholder))
+		re2, err2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=%s}`, placeholder))
+		nre, nerr := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):!%s}`, placeholder))
+		nre2, nerr2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=!%s}`, placeholder))
+		if err := errors.Join(err, err2, nerr, nerr2); err != nil {
+			return command, err
+		}
+
+		switch v := value.(type) {
+		default:
+			command = re.ReplaceAllString(command, fmt.Sprintf("$1 %v", v))
+			command = re2.ReplaceAllString(command, fmt.Sprintf("$1=%v", v))
+		case bool:
+			const FLAG = "$1"
+			if v {
+				command = re.ReplaceAllString(command, FLAG)
+				command = re2.ReplaceAllString(command, FLAG)
+			} else {
+				command = nre.ReplaceAllString(command, FLAG)
+				command = nre2.ReplaceAllString(command, FLAG)
+			}
+		}
+	}
+
+	for placeholder, value := range rangeOptions {
+		// {--flag:placeholder} => --flag <value>
+		// {--flag=placeholder} => --flag=<value>
+		re, err := regexp.Compile(fmt.Sprintf(`\${([^:|^}]+):%s}`, placeholder))
+		re2, err2 := regexp.Compile(fmt.Sprintf(`\${([^=|^}]+)=%s}`, placeholder))
+		if err := errors.Join(err, err2); err != nil {
+			return command, err
+		}
+
+		command = re.ReplaceAllString(command, fmt.Sprintf("$1 %d", value))
+		command = re2.ReplaceAllString(command, fmt.Sprintf("$1=%d", value))
+	}
+	return command, nil
+}
+
+// expandFormatArgv is expandFormatOptions for argv lists. An argument made
+// of a single {--flag:placeholder} becomes the flag and the value as two
+// arguments, and arguments made of unfilled placeholders are dropped.
+func expandFormatArgv(argv []string, options FormattingOptions, rangeOptions map[string]int) ([]string, error) {
+	expanded := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		s, err := expandFormatOptions(arg, options, rangeOptions)
+		if err != nil {
+			return nil, err
+		}
+		s = removeUnfilledPlaceholders(s)
+		switch {
+		case s == "" && arg != "":
+		case flagValuePlaceholder.MatchString(arg):
+			if flag, value, ok := strings.Cut(s, " "); ok {
+				expanded = append(expanded, flag, value)
+			} else {
+				expanded = append(expanded, s)
+			}
+		default:
+			expanded = append(expanded, s)
+		}
+	}
+	return expanded, nil
+}
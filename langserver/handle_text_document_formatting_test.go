@@ -1,6 +1,7 @@
 package langserver
 
 import (
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -79,3 +80,167 @@ func TestFormattingCRLF(t *testing.T) {
 		t.Fatalf("applying edits should produce %q but got: %q", "a\r\n", got)
 	}
 }
+
+func TestFormattingStrategy(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	upper := Language{Name: "upper", FormatCommand: `tr a-z A-Z`, FormatStdin: true}
+	exclaim := Language{Name: "exclaim", FormatCommand: `sed 's/$/!/'`, FormatStdin: true}
+	broken := Language{Name: "broken", FormatCommand: `exit 2`, FormatStdin: true}
+	stopping := broken
+	stopping.FormatStopOnError = true
+	firstSuccess := broken
+	firstSuccess.FormatStrategy = "first-success"
+	priority := upper
+	priority.FormatStrategy = "priority"
+	preferred := exclaim
+	preferred.FormatPriority = 1
+	invalid := upper
+	invalid.FormatStrategy = "random"
+
+	tests := []struct {
+		name    string
+		configs []Language
+		want    string
+		err     bool
+	}{
+		{"pipeline", []Language{upper, exclaim}, "A!\n", false},
+		{"pipeline skips failures", []Language{upper, broken, exclaim}, "A!\n", false},
+		{"pipeline stops on error", []Language{upper, stopping, exclaim}, "a\n", true},
+		{"first-success", []Language{firstSuccess, upper, exclaim}, "A\n", false},
+		{"first-success all failing", []Language{firstSuccess, broken}, "a\n", false},
+		{"priority", []Language{priority, preferred}, "a!\n", false},
+		{"invalid", []Language{invalid}, "a\n", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &langHandler{
+				logger:   log.New(io.Discard, "", 0),
+				rootPath: base,
+				configs:  map[string][]Language{"vim": test.configs},
+				files: map[DocumentURI]*File{
+					uri: {LanguageID: "vim", Text: "a\n"},
+				},
+			}
+			edits, err := h.rangeFormatting(uri, Range{Position{-1, -1}, Position{-1, -1}}, FormattingOptions{})
+			if (err != nil) != test.err {
+				t.Fatalf("error should be reported: %v, but got: %v", test.err, err)
+			}
+			if got := applyEdits(t, "a\n", edits); got != test.want {
+				t.Fatalf("formatting should produce %q but got: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFormattingOutput(t *testing.T) {
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	tests := []struct {
+		name   string
+		config Language
+		want   string
+	}{
+		{
+			name: "diff exiting with 1",
+			config: Language{
+				FormatCommand: `printf -- '--- foo\n+++ foo\n@@ -1 +1 @@\n-a\n+b\n'; exit 1`,
+				FormatStdin:   true,
+				FormatOutput:  "diff",
+			},
+			want: "b\nc\n",
+		},
+		{
+			name: "in-place-tempfile",
+			config: Language{
+				FormatCommand: `tr a-z A-Z < ${TEMPFILE} > ${TEMPFILE}.out && mv ${TEMPFILE}.out ${TEMPFILE}`,
+				FormatOutput:  "in-place-tempfile",
+			},
+			want: "A\nC\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &langHandler{
+				logger:   log.New(io.Discard, "", 0),
+				rootPath: base,
+				configs:  map[string][]Language{"vim": {test.config}},
+				files: map[DocumentURI]*File{
+					uri: {LanguageID: "vim", Text: "a\nc\n"},
+				},
+			}
+			edits, err := h.rangeFormatting(uri, Range{Position{-1, -1}, Position{-1, -1}}, FormattingOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := applyEdits(t, "a\nc\n", edits); got != test.want {
+				t.Fatalf("formatting should produce %q but got: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFormattingRangeEmulation(t *testing.T) {
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	// The formatter squeezes spaces, indentation included.
+	squeeze := Language{FormatCommand: `sed 's/  */ /g'`, FormatStdin: true}
+	tests := []struct {
+		emulation string
+		text      string
+		rng       Range
+		want      string
+	}{
+		{
+			emulation: "hunks",
+			text:      "a  =  1\nx\nb  =  2\nx\nc  =  3\n",
+			rng:       Range{Position{2, 0}, Position{2, 3}},
+			want:      "a  =  1\nx\nb = 2\nx\nc  =  3\n",
+		},
+		{
+			emulation: "selection",
+			text:      "if x:\n    a  =  1\n    b  =  2\n    c  =  3\n",
+			rng:       Range{Position{1, 2}, Position{3, 0}},
+			want:      "if x:\n    a = 1\n    b = 2\n    c  =  3\n",
+		},
+		{
+			emulation: "selection",
+			text:      "x\r\n  a  =  1",
+			rng:       Range{Position{1, 0}, Position{1, 9}},
+			want:      "x\r\n  a = 1",
+		},
+		{
+			emulation: "",
+			text:      "a  =  1\nb  =  2\n",
+			rng:       Range{Position{1, 0}, Position{1, 3}},
+			want:      "a = 1\nb = 2\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.emulation, func(t *testing.T) {
+			config := squeeze
+			config.FormatRangeEmulation = test.emulation
+			h := &langHandler{
+				logger:   log.New(io.Discard, "", 0),
+				rootPath: base,
+				configs:  map[string][]Language{"vim": {config}},
+				files: map[DocumentURI]*File{
+					uri: {LanguageID: "vim", Text: test.text},
+				},
+			}
+			edits, err := h.rangeFormatting(uri, test.rng, FormattingOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := applyEdits(t, test.text, edits); got != test.want {
+				t.Fatalf("formatting should produce %q but got: %q", test.want, got)
+			}
+		})
+	}
+}
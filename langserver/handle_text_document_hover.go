@@ -5,10 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 	"unicode/utf16"
 
 	"github.com/mattn/go-unicodeclass"
@@ -48,20 +48,22 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
 		return nil, fmt.Errorf("invalid position: %v", params.Position)
 	}
-	chars := utf16.Encode([]rune(lines[params.Position.Line]))
-	if params.Position.Character < 0 || params.Position.Character > len(chars) {
+	line := lines[params.Position.Line]
+	if params.Position.Character < 0 || params.Position.Character > encodedLen(line, h.encoding()) {
 		return nil, fmt.Errorf("invalid position: %v", params.Position)
 	}
+	chars := utf16.Encode([]rune(line))
+	character := convertCharacter(line, params.Position.Character, h.encoding(), PositionEncodingUTF16)
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(uri, f.LanguageID); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.HoverCommand != "" {
 				configs = append(configs, cfg)
 			}
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
+	if cfgs := h.languageConfigs(uri, wildcard); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.HoverCommand != "" {
 				configs = append(configs, cfg)
@@ -86,7 +88,7 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 				if strings.ContainsRune(config.HoverChars, rune(char)) {
 					continue
 				}
-				if i <= params.Position.Character {
+				if i <= character {
 					prevPos = i
 				} else {
 					currPos = i
@@ -109,18 +111,24 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 		}
 		command = strings.Replace(command, "${INPUT}", word, -1)
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		c := shellCommand{
+			Command: command,
+			Dir:     h.findRootPath(fname, config),
+			Env:     append(os.Environ(), config.Env...),
+			Timeout: time.Duration(config.HoverTimeout),
+
+			Tool:        config.HoverCommand,
+			MaxParallel: config.MaxParallel,
+			Priority:    priorityInteractive,
+		}
+		if len(config.HoverArgv) > 0 {
+			c.Argv = replaceArgv(withInput(config.HoverArgv, config.HoverStdin), "${INPUT}", word)
+			command = strings.Join(c.Argv, " ")
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.HoverStdin {
-			cmd.Stdin = strings.NewReader(word)
+			c.Stdin = strings.NewReader(word)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.output(context.Background(), c)
 		if err != nil {
 			return nil, err
 		}
@@ -141,11 +149,11 @@ func (h *langHandler) hover(uri DocumentURI, params *HoverParams) (*Hover, error
 			Range: &Range{
 				Start: Position{
 					Line:      params.Position.Line,
-					Character: prevPos,
+					Character: convertCharacter(line, prevPos, PositionEncodingUTF16, h.encoding()),
 				},
 				End: Position{
 					Line:      params.Position.Line,
-					Character: currPos,
+					Character: convertCharacter(line, currPos, PositionEncodingUTF16, h.encoding()),
 				},
 			},
 		}, nil
@@ -0,0 +1,35 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *langHandler) handleTextDocumentOnTypeFormatting(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DocumentOnTypeFormattingParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	// The line the character was typed in is formatted, so formatters need
+	// range support or format-range-emulation for the rest of the document
+	// to be left as it is.
+	line := params.Position.Line
+	rng := Range{Start: Position{Line: line}, End: Position{Line: line + 1}}
+	return h.formatWithin(params.TextDocument.URI, rng, params.Options, func(cfg Language) bool {
+		return canFormatRange(cfg) && slices.Contains(cfg.FormatOnTypeChars, params.Ch)
+	})
+}
+
+// canFormatRange reports whether the formatter cfg can format a range of
+// the document, by itself or emulated.
+func canFormatRange(cfg Language) bool {
+	return cfg.FormatCanRange || cfg.FormatRangeEmulation != ""
+}
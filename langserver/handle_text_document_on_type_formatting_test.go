@@ -0,0 +1,95 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestOnTypeFormatting(t *testing.T) {
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	text := "a\nb\nc\n"
+
+	h := &langHandler{
+		logger:   log.New(io.Discard, "", 0),
+		rootPath: base,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					FormatCommand:        `tr a-z A-Z`,
+					FormatStdin:          true,
+					FormatRangeEmulation: formatRangeHunks,
+					FormatOnTypeChars:    []string{";", "}"},
+				},
+				// Formatters of the whole document are not run on type.
+				{
+					FormatCommand:     `sed 's/$/!/'`,
+					FormatStdin:       true,
+					FormatOnTypeChars: []string{";", "!"},
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "vim", Text: text},
+		},
+	}
+
+	tests := []struct {
+		ch   string
+		want string
+	}{
+		{";", "a\nB\nc\n"},
+		{"!", text},
+		{"\n", text},
+	}
+	for _, test := range tests {
+		params, err := json.Marshal(DocumentOnTypeFormattingParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Position:     Position{Line: 1, Character: 1},
+			},
+			Ch: test.ch,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := json.RawMessage(params)
+
+		result, err := h.handleTextDocumentOnTypeFormatting(context.Background(), nil, &jsonrpc2.Request{Params: &raw})
+		if err != nil {
+			t.Fatal(err)
+		}
+		edits, _ := result.([]TextEdit)
+		if got := applyEdits(t, text, edits); got != test.want {
+			t.Fatalf("typing %q should produce %q but got: %q", test.ch, test.want, got)
+		}
+	}
+}
+
+func TestOnTypeFormattingProvider(t *testing.T) {
+	h := &langHandler{
+		logger: log.New(io.Discard, "", 0),
+		configs: map[string][]Language{
+			"vim": {
+				{FormatCommand: "a", FormatCanRange: true, FormatOnTypeChars: []string{";"}},
+				{FormatCommand: "b", FormatOnTypeChars: []string{"!"}},
+			},
+		},
+	}
+	raw := json.RawMessage(`{}`)
+	result, err := h.handleInitialize(context.Background(), nil, &jsonrpc2.Request{Params: &raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := result.(InitializeResult).Capabilities.OnTypeFormattingProvider
+	if provider == nil || provider.FirstTriggerCharacter != ";" || len(provider.MoreTriggerCharacter) != 0 {
+		t.Fatalf("only the characters of range formatters should trigger formatting but got: %+v", provider)
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/reviewdog/errorformat"
 	"github.com/sourcegraph/jsonrpc2"
@@ -75,14 +75,14 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 	}
 
 	var configs []Language
-	if cfgs, ok := h.configs[f.LanguageID]; ok {
+	if cfgs := h.languageConfigs(uri, f.LanguageID); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.SymbolCommand != "" {
 				configs = append(configs, cfg)
 			}
 		}
 	}
-	if cfgs, ok := h.configs[wildcard]; ok {
+	if cfgs := h.languageConfigs(uri, wildcard); len(cfgs) > 0 {
 		for _, cfg := range cfgs {
 			if cfg.SymbolCommand != "" {
 				configs = append(configs, cfg)
@@ -112,24 +112,30 @@ func (h *langHandler) symbol(uri DocumentURI) ([]SymbolInformation, error) {
 			formats = []string{"%f:%l:%m", "%f:%l:%c:%m"}
 		}
 
-		efms, err := errorformat.NewErrorformat(formats)
+		efms, err := newErrorformat(formats)
 		if err != nil {
 			h.logger.Println("invalid error-format")
 			return nil, fmt.Errorf("invalid error-format: %v", config.SymbolFormats)
 		}
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.Command("cmd", "/c", command)
-		} else {
-			cmd = exec.Command("sh", "-c", command)
+		c := shellCommand{
+			Command: command,
+			Dir:     h.findRootPath(fname, config),
+			Env:     append(os.Environ(), config.Env...),
+			Timeout: time.Duration(config.SymbolTimeout),
+
+			Tool:        config.SymbolCommand,
+			MaxParallel: config.MaxParallel,
+			Priority:    priorityInteractive,
+		}
+		if len(config.SymbolArgv) > 0 {
+			c.Argv = replaceArgvInputFilename(withInput(config.SymbolArgv, config.SymbolStdin), fname, h.rootPath)
+			command = strings.Join(c.Argv, " ")
 		}
-		cmd.Dir = h.findRootPath(fname, config)
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.SymbolStdin {
-			cmd.Stdin = strings.NewReader(f.Text)
+			c.Stdin = strings.NewReader(f.Text)
 		}
-		b, err := cmd.CombinedOutput()
+		b, err := h.output(context.Background(), c)
 		if err != nil {
 			continue
 		}
@@ -0,0 +1,55 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// defaultFormatWait is the format-wait-timeout used when it is not set.
+const defaultFormatWait = 3 * time.Second
+
+func (h *langHandler) handleTextDocumentWillSaveWaitUntil(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params WillSaveTextDocumentParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	// Documents saved after a delay or when the editor loses focus are
+	// left as they are, so that they do not change while being edited.
+	if params.Reason != Manual {
+		return nil, nil
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	return h.formatWithin(params.TextDocument.URI, rng, FormattingOptions{}, func(cfg Language) bool {
+		return cfg.FormatOnSave
+	})
+}
+
+// formatWithin is formatDocument giving up after format-wait-timeout, in
+// which case the document is left as it is, so that clients waiting for
+// the edits do not hang on a slow formatter.
+func (h *langHandler) formatWithin(uri DocumentURI, rng Range, options FormattingOptions, use func(Language) bool) ([]TextEdit, error) {
+	h.mu.Lock()
+	wait := h.formatWait
+	h.mu.Unlock()
+	if wait <= 0 {
+		wait = defaultFormatWait
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	edits, err := h.formatDocument(ctx, uri, rng, options, use)
+	if ctx.Err() != nil {
+		h.logger.Printf("format of %v given up after %v", uri, wait)
+		return nil, nil
+	}
+	return edits, err
+}
@@ -0,0 +1,70 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestWillSaveWaitUntil(t *testing.T) {
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	upper := Language{Name: "upper", FormatCommand: `tr a-z A-Z`, FormatStdin: true, FormatOnSave: true}
+	exclaim := Language{Name: "exclaim", FormatCommand: `sed 's/$/!/'`, FormatStdin: true}
+	slow := Language{Name: "slow", FormatCommand: `sleep 10`, FormatStdin: true, FormatOnSave: true}
+
+	tests := []struct {
+		name    string
+		configs []Language
+		reason  TextDocumentSaveReason
+		want    string
+	}{
+		{"format-on-save only", []Language{upper, exclaim}, Manual, "A\n"},
+		{"none on save", []Language{exclaim}, Manual, "a\n"},
+		{"given up", []Language{upper, slow}, Manual, "a\n"},
+		{"after delay", []Language{upper}, AfterDelay, "a\n"},
+		{"focus out", []Language{upper}, FocusOut, "a\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &langHandler{
+				logger:     log.New(io.Discard, "", 0),
+				rootPath:   base,
+				formatWait: 200 * time.Millisecond,
+				configs:    map[string][]Language{"vim": test.configs},
+				files: map[DocumentURI]*File{
+					uri: {LanguageID: "vim", Text: "a\n"},
+				},
+			}
+			params, err := json.Marshal(WillSaveTextDocumentParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Reason:       test.reason,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := json.RawMessage(params)
+
+			start := time.Now()
+			result, err := h.handleTextDocumentWillSaveWaitUntil(context.Background(), nil, &jsonrpc2.Request{Params: &raw})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("saving should not wait for the formatters but took: %v", elapsed)
+			}
+			edits, _ := result.([]TextEdit)
+			if got := applyEdits(t, "a\n", edits); got != test.want {
+				t.Fatalf("formatting should produce %q but got: %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// codeRequestCancelled is the LSP error code of requests cancelled by the
+// client, or given up by a server shutting down.
+const codeRequestCancelled = -32800
+
+// jsonrpcHandler answers requests with handle one at a time, but for the
+// diagnostic requests and codeAction/resolve, which run tools or are held
+// open until diagnostics change, and are thus answered in the background. The messages following
+// them, such as the changes making them stale or their cancellation, are
+// not kept waiting.
+type jsonrpcHandler struct {
+	h    *langHandler
+	next jsonrpc2.Handler
+}
+
+func (h *langHandler) jsonrpcHandler() jsonrpc2.Handler {
+	return &jsonrpcHandler{h: h, next: jsonrpc2.HandlerWithError(h.handle)}
+}
+
+// Handle implements jsonrpc2.Handler.
+func (j *jsonrpcHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var background func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error)
+	switch req.Method {
+	case "codeAction/resolve":
+		background = j.h.handleCodeActionResolve
+	case "textDocument/diagnostic":
+		background = j.h.handleTextDocumentDiagnostic
+	case "workspace/diagnostic":
+		background = j.h.handleWorkspaceDiagnostic
+	}
+	if background == nil || req.Notif {
+		j.next.Handle(ctx, conn, req)
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if !j.h.addPendingRequest(req.ID, cancel) {
+		cancel()
+		if err := conn.ReplyWithError(ctx, req.ID, &jsonrpc2.Error{Code: codeRequestCancelled, Message: "server is shutting down"}); err != nil {
+			j.h.logger.Println(err)
+		}
+		return
+	}
+	go func() {
+		defer j.h.removePendingRequest(req.ID)
+		result, err := background(ctx, conn, req)
+		if ctx.Err() != nil {
+			err = &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"}
+		}
+		if err != nil {
+			e, ok := err.(*jsonrpc2.Error)
+			if !ok {
+				e = &jsonrpc2.Error{Message: err.Error()}
+			}
+			err = conn.ReplyWithError(context.Background(), req.ID, e)
+		} else {
+			err = conn.Reply(context.Background(), req.ID, result)
+		}
+		if err != nil && err != jsonrpc2.ErrClosed {
+			j.h.logger.Println(err)
+		}
+	}()
+}
+
+// addPendingRequest remembers the cancel func of a request answered in the
+// background, unless the server is shut down.
+func (h *langHandler) addPendingRequest(id jsonrpc2.ID, cancel context.CancelFunc) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.isShutdown {
+		return false
+	}
+	if h.pendingRequests == nil {
+		h.pendingRequests = make(map[jsonrpc2.ID]context.CancelFunc)
+	}
+	h.pendingRequests[id] = cancel
+	return true
+}
+
+func (h *langHandler) removePendingRequest(id jsonrpc2.ID) {
+	h.mu.Lock()
+	cancel, ok := h.pendingRequests[id]
+	delete(h.pendingRequests, id)
+	h.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (h *langHandler) handleCancelRequest(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params CancelParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	cancel, ok := h.pendingRequests[params.ID]
+	h.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil, nil
+}
+
+func (h *langHandler) handleWorkspaceDiagnostic(ctx context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params WorkspaceDiagnosticParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	return h.workspaceDiagnostic(ctx, &params)
+}
+
+// workspaceChangeLocked returns a channel closed once the documents or
+// what they depend on change. h.mu must be held.
+func (h *langHandler) workspaceChangeLocked() chan struct{} {
+	if h.workspaceChange == nil {
+		h.workspaceChange = make(chan struct{})
+	}
+	return h.workspaceChange
+}
+
+// workspaceChangedLocked wakes up the workspace/diagnostic requests waiting
+// for a change. h.mu must be held.
+func (h *langHandler) workspaceChangedLocked() {
+	if h.workspaceChange != nil {
+		close(h.workspaceChange)
+		h.workspaceChange = nil
+	}
+}
+
+// workspaceDiagnostic reports the diagnostics of the open documents. As the
+// client asks again as soon as it is answered, a client which already has
+// the last results is only answered once something changed and the
+// diagnostics differ from those it has.
+func (h *langHandler) workspaceDiagnostic(ctx context.Context, params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
+	previous := make(map[DocumentURI]string)
+	for _, id := range params.PreviousResultIDs {
+		previous[id.URI] = id.Value
+	}
+
+	for {
+		h.mu.Lock()
+		changed := h.workspaceChangeLocked()
+		upToDate := len(previous) > 0 && h.workspaceLinted == changed
+		for uri, id := range previous {
+			if h.diagnosticResults[uri].ID != id {
+				upToDate = false
+			}
+		}
+		h.mu.Unlock()
+
+		if !upToDate {
+			report, err := h.lintWorkspace(ctx, previous)
+			if err != nil {
+				return nil, err
+			}
+			h.mu.Lock()
+			h.workspaceLinted = changed
+			h.mu.Unlock()
+			if len(previous) == 0 || !unchangedReport(report, previous) {
+				return report, nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// unchangedReport reports whether report only tells that the documents with
+// results in previous are unchanged.
+func unchangedReport(report *WorkspaceDiagnosticReport, previous map[DocumentURI]string) bool {
+	for _, item := range report.Items {
+		if _, ok := previous[item.URI]; !ok || item.Kind != UnchangedReport {
+			return false
+		}
+	}
+	return true
+}
+
+// lintWorkspace lints the open documents and reports their diagnostics.
+func (h *langHandler) lintWorkspace(ctx context.Context, previous map[DocumentURI]string) (*WorkspaceDiagnosticReport, error) {
+	h.mu.Lock()
+	versions := make(map[DocumentURI]int)
+	for uri, f := range h.files {
+		versions[uri] = f.Version
+	}
+	h.mu.Unlock()
+
+	// Other documents reported through lint-workspace are only taken when
+	// they were not linted on their own.
+	all := make(map[DocumentURI][]Diagnostic)
+	for uri := range versions {
+		uriToDiagnostics, err := h.pullLint(ctx, uri)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			h.logger.Println(err)
+			continue
+		}
+		for diagURI, diagnostics := range uriToDiagnostics {
+			if _, ok := all[diagURI]; ok && diagURI != uri {
+				continue
+			}
+			all[diagURI] = diagnostics
+		}
+	}
+
+	report := &WorkspaceDiagnosticReport{Items: []WorkspaceDocumentDiagnosticReport{}}
+	for uri, diagnostics := range all {
+		item := WorkspaceDocumentDiagnosticReport{
+			DocumentDiagnosticReport: h.diagnosticReport(uri, diagnostics, previous[uri]),
+			URI:                      uri,
+		}
+		if version, ok := versions[uri]; ok {
+			item.Version = &version
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report, nil
+}
@@ -24,6 +24,12 @@ func (h *langHandler) handleWorkspaceDidChangeConfiguration(_ context.Context, _
 }
 
 func (h *langHandler) didChangeConfiguration(config *Config) (any, error) {
+	if config.Languages != nil {
+		if err := resolveTools(*config.Languages, config.Tools); err != nil {
+			return nil, err
+		}
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if config.Languages != nil {
@@ -47,6 +53,28 @@ func (h *langHandler) didChangeConfiguration(config *Config) (any, error) {
 	if config.FormatDebounce > 0 {
 		h.formatDebounce = time.Duration(config.FormatDebounce)
 	}
+	if config.FormatWaitTimeout > 0 {
+		h.formatWait = time.Duration(config.FormatWaitTimeout)
+	}
+	if config.CommandTimeout > 0 {
+		h.commandTimeout = time.Duration(config.CommandTimeout)
+	}
+	if config.CommandOutputLimit > 0 {
+		h.outputLimit = config.CommandOutputLimit
+	}
+	if config.LintCache != "" {
+		cache, err := newLintCache(config.LintCache)
+		if err != nil {
+			return nil, err
+		}
+		h.lintCache = cache
+	}
+	if config.Languages != nil {
+		go h.registerWatchedFiles()
+	}
+	if config.MaxParallel > 0 && h.executor != nil {
+		h.executor.setMaxParallel(config.MaxParallel)
+	}
 
 	if config.LogFile != "" {
 		f, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o660)
@@ -0,0 +1,170 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// watchedFilesRegistration identifies the registration of the watch-patterns
+// of the languages, which is replaced when they change.
+const watchedFilesRegistration = "efm-langserver/watchedFiles"
+
+func (h *langHandler) handleWorkspaceDidChangeWatchedFiles(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	if req.Params == nil {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+
+	var params DidChangeWatchedFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return nil, err
+	}
+
+	h.didChangeWatchedFiles(&params)
+	return nil, nil
+}
+
+// didChangeWatchedFiles lints the open documents whose tools watch the
+// changed files again, and clears the diagnostics of deleted files. The
+// temporary files written for lint-tempfile and format-tempfile are left
+// out, as linting again would write them again.
+func (h *langHandler) didChangeWatchedFiles(params *DidChangeWatchedFilesParams) {
+	h.mu.Lock()
+	var changed []string
+	deleted := make(map[DocumentURI]struct{})
+	for _, change := range params.Changes {
+		fname, err := fromURI(change.URI)
+		if err != nil || isTempfile(fname) {
+			continue
+		}
+		changed = append(changed, fname)
+		if _, ok := h.files[change.URI]; ok || change.Type != FileDeleted {
+			continue
+		}
+		for _, published := range h.lastPublishedURIs {
+			if _, ok := published[change.URI]; ok {
+				delete(published, change.URI)
+				deleted[change.URI] = struct{}{}
+			}
+		}
+	}
+	var uris []DocumentURI
+	for uri, f := range h.files {
+		if watches(h.documentConfigs(uri, f.LanguageID), changed) {
+			uris = append(uris, uri)
+		}
+	}
+	conn := h.conn
+	cache := h.lintCache
+	h.mu.Unlock()
+
+	if conn != nil {
+		for uri := range deleted {
+			conn.Notify(
+				context.Background(),
+				"textDocument/publishDiagnostics",
+				&PublishDiagnosticsParams{
+					URI:         uri,
+					Diagnostics: []Diagnostic{},
+				})
+		}
+	}
+	if len(uris) == 0 {
+		return
+	}
+	// Cached results do not know about the files changed.
+	cache.invalidate()
+	h.lintAgain(uris)
+}
+
+// watches reports whether any of configs watches any of fnames.
+func watches(configs []Language, fnames []string) bool {
+	for _, cfg := range configs {
+		for _, pattern := range cfg.WatchPatterns {
+			for _, fname := range fnames {
+				if matchGlob(pattern, fname) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// registerWatchedFiles asks the client to watch the files matching the
+// watch-patterns of the languages, in place of those registered before.
+func (h *langHandler) registerWatchedFiles() {
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+
+	h.mu.Lock()
+	conn := h.conn
+	if !h.watchFiles || conn == nil {
+		h.mu.Unlock()
+		return
+	}
+	set := make(map[string]struct{})
+	languages := []map[string][]Language{h.configs}
+	for _, p := range h.projects {
+		if p.trusted {
+			languages = append(languages, p.languages)
+		}
+	}
+	for _, configs := range languages {
+		for _, cfgs := range configs {
+			for _, cfg := range cfgs {
+				for _, pattern := range cfg.WatchPatterns {
+					set[pattern] = struct{}{}
+				}
+			}
+		}
+	}
+	logger := h.logger
+	h.mu.Unlock()
+
+	patterns := make([]string, 0, len(set))
+	for pattern := range set {
+		patterns = append(patterns, pattern)
+	}
+	slices.Sort(patterns)
+	if slices.Equal(patterns, h.watchedPatterns) {
+		return
+	}
+
+	ctx := context.Background()
+	if len(h.watchedPatterns) > 0 {
+		err := conn.Call(ctx, "client/unregisterCapability", &UnregistrationParams{
+			Unregisterations: []Unregistration{
+				{ID: watchedFilesRegistration, Method: "workspace/didChangeWatchedFiles"},
+			},
+		}, nil)
+		if err != nil {
+			logger.Println(err)
+			return
+		}
+		h.watchedPatterns = nil
+	}
+	if len(patterns) == 0 {
+		return
+	}
+	watchers := make([]FileSystemWatcher, len(patterns))
+	for i, pattern := range patterns {
+		watchers[i] = FileSystemWatcher{GlobPattern: pattern}
+	}
+	err := conn.Call(ctx, "client/registerCapability", &RegistrationParams{
+		Registrations: []Registration{
+			{
+				ID:              watchedFilesRegistration,
+				Method:          "workspace/didChangeWatchedFiles",
+				RegisterOptions: DidChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+			},
+		},
+	}, nil)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	h.watchedPatterns = patterns
+}
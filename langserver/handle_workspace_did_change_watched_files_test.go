@@ -0,0 +1,175 @@
+package langserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDidChangeWatchedFiles(t *testing.T) {
+	h := &langHandler{
+		lintDebounce: 10 * time.Millisecond,
+		request:      make(chan lintRequest, 10),
+		pendingLints: make(map[DocumentURI]eventType),
+		configs: map[string][]Language{
+			"typescript": {
+				{
+					LintCommand:   "tsc",
+					LintWorkspace: true,
+					WatchPatterns: []string{"**/*.ts", "tsconfig.json"},
+				},
+			},
+			"vim": {
+				{
+					LintCommand: "vint",
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			"file:///project/a.ts": {
+				LanguageID: "typescript",
+			},
+			"file:///project/a.vim": {
+				LanguageID: "vim",
+			},
+		},
+		lastPublishedURIs: map[string]map[DocumentURI]struct{}{
+			"typescript": {
+				"file:///project/a.ts": {},
+				"file:///project/b.ts": {},
+			},
+		},
+	}
+
+	h.didChangeWatchedFiles(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{
+			{URI: "file:///project/b.ts", Type: FileDeleted},
+		},
+	})
+
+	select {
+	case req := <-h.request:
+		if req.URI != "file:///project/a.ts" || req.EventType != eventTypeSave {
+			t.Fatalf("only the typescript document should be linted again but got: %v", req)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the lint request")
+	}
+	select {
+	case req := <-h.request:
+		t.Fatalf("only the typescript document should be linted again but got: %v", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, ok := h.lastPublishedURIs["typescript"]["file:///project/b.ts"]; ok {
+		t.Fatal("the deleted file should not be published to anymore")
+	}
+	if _, ok := h.lastPublishedURIs["typescript"]["file:///project/a.ts"]; !ok {
+		t.Fatal("the open document should still be published to")
+	}
+
+	h.didChangeWatchedFiles(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{
+			{URI: "file:///project/README.md", Type: FileChanged},
+		},
+	})
+	select {
+	case req := <-h.request:
+		t.Fatalf("no document should be linted for an unwatched file but got: %v", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDidChangeWatchedFilesTempfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell redirect")
+	}
+	dir := t.TempDir()
+	uri := toURI(filepath.Join(dir, "a.py"))
+	written := filepath.Join(t.TempDir(), "written")
+
+	h := &langHandler{
+		logger:       log.New(io.Discard, "", 0),
+		rootPath:     dir,
+		lintDebounce: 10 * time.Millisecond,
+		request:      make(chan lintRequest, 10),
+		pendingLints: make(map[DocumentURI]eventType),
+		configs: map[string][]Language{
+			"python": {
+				{
+					LintCommand:        `echo ${TEMPFILE} >> ` + written,
+					LintIgnoreExitCode: true,
+					LintTempfile:       true,
+					WatchPatterns:      []string{"**/*.py"},
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "python", Text: "a\n"},
+		},
+	}
+	if _, err := h.lint(context.Background(), uri, eventTypeChange); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := toURI(strings.TrimSpace(string(b)))
+
+	// The client watching **/*.py sees the temporary file come and go,
+	// which must not lint the document again, and so on.
+	h.didChangeWatchedFiles(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{
+			{URI: tmp, Type: FileCreated},
+			{URI: tmp, Type: FileDeleted},
+		},
+	})
+	select {
+	case req := <-h.request:
+		t.Fatalf("the temporary file should not lint the document again but got: %v", req)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.didChangeWatchedFiles(&DidChangeWatchedFilesParams{
+		Changes: []FileEvent{{URI: toURI(filepath.Join(dir, "b.py")), Type: FileChanged}},
+	})
+	select {
+	case <-h.request:
+	case <-time.After(3 * time.Second):
+		t.Fatal("a watched file should lint the document again")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		fname   string
+		want    bool
+	}{
+		{"*.go", "/a/b/c.go", true},
+		{"*.go", "/a/b/c.goo", false},
+		{"**/*.go", "/c.go", true},
+		{"/a/*.go", "/a/b/c.go", false},
+		{"/a/**/*.go", "/a/b/c.go", true},
+		{"src/*.{ts,tsx}", "/project/src/a.tsx", true},
+		{"src/*.{ts,tsx}", "/project/lib/a.ts", false},
+		{"tags", "/project/tags", true},
+		{"file?.[!a-c]", "/file1.d", true},
+		{"file?.[!a-c]", "/file1.a", false},
+		{"{a", "/a", false},
+	}
+	// The second round matches the compiled patterns again.
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			if got := matchGlob(test.pattern, test.fname); got != test.want {
+				t.Errorf("matchGlob(%q, %q) should be %v", test.pattern, test.fname, test.want)
+			}
+		}
+	}
+}
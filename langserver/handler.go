@@ -1,8 +1,8 @@
 package langserver
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -10,17 +10,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
 	"unicode/utf16"
 
-	"github.com/reviewdog/errorformat"
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/mattn/go-unicodeclass"
+	"gopkg.in/yaml.v3"
 )
 
 type eventType int
@@ -43,16 +42,40 @@ type Config struct {
 	LogLevel       int                    `yaml:"log-level"       json:"logLevel"`
 	Commands       *[]Command             `yaml:"commands"        json:"commands"`
 	Languages      *map[string][]Language `yaml:"languages"       json:"languages"`
+	Tools          map[string]Language    `yaml:"tools"           json:"tools"`
+	Include        []string               `yaml:"include"         json:"-"`
 	RootMarkers    *[]string              `yaml:"root-markers"    json:"rootMarkers"`
 	TriggerChars   []string               `yaml:"trigger-chars"   json:"triggerChars"`
 	LintDebounce   Duration               `yaml:"lint-debounce"   json:"lintDebounce"`
 	FormatDebounce Duration               `yaml:"format-debounce" json:"formatDebounce"`
 
+	// FormatWaitTimeout is how long formatting on save and on type may
+	// take before it is given up, so that clients waiting for it are not
+	// blocked on a slow formatter.
+	FormatWaitTimeout Duration `yaml:"format-wait-timeout" json:"formatWaitTimeout"`
+
+	// CommandTimeout and CommandOutputLimit apply to every command run by
+	// the server, unless a tool sets its own timeout.
+	CommandTimeout     Duration `yaml:"command-timeout"      json:"commandTimeout"`
+	CommandOutputLimit int      `yaml:"command-output-limit" json:"commandOutputLimit"`
+
+	// MaxParallel limits how many commands run at once. Defaults to the
+	// number of CPUs.
+	MaxParallel int `yaml:"max-parallel" json:"maxParallel"`
+
+	// LintCache is where lint results are cached: memory, disk, or off (the
+	// default).
+	LintCache string `yaml:"lint-cache" json:"lintCache"`
+
 	// Toggle support for "go to definition" requests.
 	ProvideDefinition bool `yaml:"provide-definition"`
 
 	Filename string      `yaml:"-"`
 	Logger   *log.Logger `yaml:"-"`
+
+	// IncludedFiles are the files included by the configuration file,
+	// directly or not.
+	IncludedFiles []string `yaml:"-" json:"-"`
 }
 
 // Config1 is
@@ -67,9 +90,12 @@ type Config1 struct {
 type Language struct {
 	Prefix               string            `yaml:"prefix" json:"prefix"`
 	LintFormats          []string          `yaml:"lint-formats" json:"lintFormats"`
+	LintOutputFormat     string            `yaml:"lint-output-format" json:"lintOutputFormat"`
+	LintJSONPath         *LintJSONPath     `yaml:"lint-json-path" json:"lintJsonPath"`
 	LintStdin            bool              `yaml:"lint-stdin" json:"lintStdin"`
 	LintOffset           int               `yaml:"lint-offset" json:"lintOffset"`
 	LintOffsetColumns    int               `yaml:"lint-offset-columns" json:"lintOffsetColumns"`
+	LintColumnUnit       string            `yaml:"lint-column-unit" json:"lintColumnUnit"`
 	LintCommand          string            `yaml:"lint-command" json:"lintCommand"`
 	LintIgnoreExitCode   bool              `yaml:"lint-ignore-exit-code" json:"lintIgnoreExitCode"`
 	LintCategoryMap      map[string]string `yaml:"lint-category-map" json:"lintCategoryMap"`
@@ -78,31 +104,86 @@ type Language struct {
 	LintWorkspace        bool              `yaml:"lint-workspace" json:"lintWorkspace"`
 	LintAfterOpen        bool              `yaml:"lint-after-open" json:"lintAfterOpen"`
 	LintOnSave           bool              `yaml:"lint-on-save" json:"lintOnSave"`
+	LintFixCommand       string            `yaml:"lint-fix-command" json:"lintFixCommand"`
+	LintTimeout          Duration          `yaml:"lint-timeout" json:"lintTimeout"`
+	LintTempfile         bool              `yaml:"lint-tempfile" json:"lintTempfile"`
 	FormatCommand        string            `yaml:"format-command" json:"formatCommand"`
 	FormatCanRange       bool              `yaml:"format-can-range" json:"formatCanRange"`
 	FormatIgnoreExitCode bool              `yaml:"format-ignore-exit-code" json:"formatIgnoreExitCode"`
 	FormatStdin          bool              `yaml:"format-stdin" json:"formatStdin"`
+	FormatTimeout        Duration          `yaml:"format-timeout" json:"formatTimeout"`
+	FormatTempfile       bool              `yaml:"format-tempfile" json:"formatTempfile"`
+	FormatStrategy       string            `yaml:"format-strategy" json:"formatStrategy"`
+	FormatPriority       int               `yaml:"format-priority" json:"formatPriority"`
+	FormatStopOnError    bool              `yaml:"format-stop-on-error" json:"formatStopOnError"`
+	FormatOutput         string            `yaml:"format-output" json:"formatOutput"`
+	FormatRangeEmulation string            `yaml:"format-range-emulation" json:"formatRangeEmulation"`
+	FormatOnSave         bool              `yaml:"format-on-save" json:"formatOnSave"`
+	FormatOnTypeChars    []string          `yaml:"format-on-type-chars" json:"formatOnTypeChars"`
 	SymbolCommand        string            `yaml:"symbol-command" json:"symbolCommand"`
 	SymbolStdin          bool              `yaml:"symbol-stdin" json:"symbolStdin"`
 	SymbolFormats        []string          `yaml:"symbol-formats" json:"symbolFormats"`
+	SymbolTimeout        Duration          `yaml:"symbol-timeout" json:"symbolTimeout"`
 	CompletionCommand    string            `yaml:"completion-command" json:"completionCommand"`
 	CompletionStdin      bool              `yaml:"completion-stdin" json:"completionStdin"`
+	CompletionTimeout    Duration          `yaml:"completion-timeout" json:"completionTimeout"`
 	HoverCommand         string            `yaml:"hover-command" json:"hoverCommand"`
 	HoverStdin           bool              `yaml:"hover-stdin" json:"hoverStdin"`
 	HoverType            string            `yaml:"hover-type" json:"hoverType"`
 	HoverChars           string            `yaml:"hover-chars" json:"hoverChars"`
+	HoverTimeout         Duration          `yaml:"hover-timeout" json:"hoverTimeout"`
 	Env                  []string          `yaml:"env" json:"env"`
 	RootMarkers          []string          `yaml:"root-markers" json:"rootMarkers"`
 	RequireMarker        bool              `yaml:"require-marker" json:"requireMarker"`
 	Commands             []Command         `yaml:"commands" json:"commands"`
+	MaxParallel          int               `yaml:"max-parallel" json:"maxParallel"`
+	WatchPatterns        []string          `yaml:"watch-patterns" json:"watchPatterns"`
+	FilenamePatterns     []string          `yaml:"filename-patterns" json:"filenamePatterns"`
+	ExcludePatterns      []string          `yaml:"exclude-patterns" json:"excludePatterns"`
+	Shebang              []string          `yaml:"shebang" json:"shebang"`
+
+	// Name identifies the tool, so that a project configuration can
+	// override it instead of adding another.
+	Name string `yaml:"name" json:"name"`
+
+	// Tool names the tool of Config.Tools the tool is based on. Its own
+	// settings override those of the named tool.
+	Tool string `yaml:"tool" json:"tool"`
+
+	// Preset names the preset the tool is based on, the same as Tool.
+	Preset string `yaml:"preset" json:"preset"`
+
+	// Argv lists configured in place of the command strings, which are run
+	// without a shell. The command strings are then set to the joined argv.
+	LintArgv       []string `yaml:"-" json:"-"`
+	LintFixArgv    []string `yaml:"-" json:"-"`
+	FormatArgv     []string `yaml:"-" json:"-"`
+	SymbolArgv     []string `yaml:"-" json:"-"`
+	CompletionArgv []string `yaml:"-" json:"-"`
+	HoverArgv      []string `yaml:"-" json:"-"`
+
+	// file, node and raw are where the tool was configured, so that it can
+	// be decoded again onto the tool it is based on.
+	file string
+	node *yaml.Node
+	raw  []byte
 }
 
 // NewHandler create JSON-RPC handler for this language server.
 func NewHandler(config *Config) jsonrpc2.Handler {
+	return newHandler(config).jsonrpcHandler()
+}
+
+func newHandler(config *Config) *langHandler {
 	if config.Logger == nil {
 		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
 	}
 
+	cache, err := newLintCache(config.LintCache)
+	if err != nil {
+		config.Logger.Println(err)
+	}
+
 	handler := &langHandler{
 		loglevel:          config.LogLevel,
 		logger:            config.Logger,
@@ -116,16 +197,27 @@ func NewHandler(config *Config) jsonrpc2.Handler {
 		pendingLints:      make(map[DocumentURI]eventType),
 
 		formatDebounce: time.Duration(config.FormatDebounce),
+		formatWait:     time.Duration(config.FormatWaitTimeout),
 		formatTimer:    nil,
+		commandTimeout: time.Duration(config.CommandTimeout),
+		outputLimit:    config.CommandOutputLimit,
+		executor:       newExecutor(config.MaxParallel),
+		lintCache:      cache,
 		conn:           nil,
 		filename:       config.Filename,
+		includes:       config.IncludedFiles,
+		trustFile:      defaultTrustFile(),
 		rootMarkers:    *config.RootMarkers,
 		triggerChars:   config.TriggerChars,
 
 		lastPublishedURIs: make(map[string]map[DocumentURI]struct{}),
+		quickFixes:        make(map[DocumentURI][]quickFix),
+		fixDiffs:          make(map[DocumentURI]*fixDiffs),
+		pullEvents:        make(map[DocumentURI]eventType),
+		diagnosticResults: make(map[DocumentURI]diagnosticResult),
 	}
 	go handler.linter()
-	return jsonrpc2.HandlerWithError(handler.handle)
+	return handler
 }
 
 type langHandler struct {
@@ -142,17 +234,83 @@ type langHandler struct {
 	pendingLints      map[DocumentURI]eventType
 	isShutdown        bool
 	formatDebounce    time.Duration
+	formatWait        time.Duration
 	formatTimer       *time.Timer
+	commandTimeout    time.Duration
+	outputLimit       int
+	executor          *executor
+	lintCache         *lintCache
 	conn              *jsonrpc2.Conn
 	rootPath          string
 	filename          string
+	includes          []string
 	folders           []string
 	rootMarkers       []string
 	triggerChars      []string
 
+	// positionEncoding is the encoding of Position.Character negotiated with
+	// the client. Empty means UTF-16, the default of the protocol.
+	positionEncoding PositionEncodingKind
+
 	// lastPublishedURIs is mapping from LanguageID string to mapping of
 	// whether diagnostics are published in a DocumentURI or not.
 	lastPublishedURIs map[string]map[DocumentURI]struct{}
+
+	// quickFixes holds the fixes linters suggested along with the
+	// diagnostics last published for each DocumentURI.
+	quickFixes map[DocumentURI][]quickFix
+
+	// resolveCodeActions is set when the client resolves the edits of code
+	// actions, so that lint-fix-command only runs for the chosen action.
+	// fixDiffs keeps the diff printed by each lint-fix-command for the
+	// current version of each DocumentURI.
+	resolveCodeActions bool
+	fixDiffs           map[DocumentURI]*fixDiffs
+
+	// pullDiagnostics is set when the client requests diagnostics through
+	// textDocument/diagnostic. Linters then run on demand instead of being
+	// triggered by document events, pullEvents keeps the last event of
+	// each document to decide which linters apply, and pullChanged when it
+	// last changed, for lint-debounce.
+	pullDiagnostics   bool
+	pullEvents        map[DocumentURI]eventType
+	pullChanged       map[DocumentURI]time.Time
+	diagnosticResults map[DocumentURI]diagnosticResult
+	lastResultID      int
+	// diagnosticRefresh is set when the client can be asked to pull
+	// diagnostics again.
+	diagnosticRefresh bool
+	// workspaceChange is closed when documents change, waking up the
+	// workspace/diagnostic requests held open until then, and
+	// workspaceLinted is the one documents were last linted for by such a
+	// request. pendingRequests cancel the requests answered in the
+	// background.
+	workspaceChange chan struct{}
+	workspaceLinted chan struct{}
+	pendingRequests map[jsonrpc2.ID]context.CancelFunc
+
+	// stopWatchConfig stops watching the configuration file for changes.
+	stopWatchConfig context.CancelFunc
+
+	// projects are the project configuration files found by their path,
+	// whose trust is remembered in trustFile.
+	projects  map[string]*projectConfig
+	trustFile string
+
+	// watchFiles is set when the client can watch files for the server,
+	// which then registers the watch-patterns of the languages. watchMu
+	// serializes the registrations, and guards watchedPatterns.
+	watchFiles      bool
+	watchMu         sync.Mutex
+	watchedPatterns []string
+}
+
+// encoding returns the position encoding negotiated with the client.
+func (h *langHandler) encoding() PositionEncodingKind {
+	if h.positionEncoding == "" {
+		return PositionEncodingUTF16
+	}
+	return h.positionEncoding
 }
 
 // File is
@@ -240,6 +398,15 @@ func (h *langHandler) lintRequest(uri DocumentURI, event eventType) {
 	if h.isShutdown {
 		return
 	}
+	if h.pullDiagnostics {
+		h.pullEvents[uri] = event
+		if h.pullChanged == nil {
+			h.pullChanged = make(map[DocumentURI]time.Time)
+		}
+		h.pullChanged[uri] = time.Now()
+		h.workspaceChangedLocked()
+		return
+	}
 	h.pendingLints[uri] = event
 	if h.lintTimer != nil {
 		h.lintTimer.Reset(h.lintDebounce)
@@ -261,7 +428,25 @@ func (h *langHandler) lintRequest(uri DocumentURI, event eventType) {
 	})
 }
 
+func (h *langHandler) showMessage(typ MessageType, message string) {
+	if h.conn == nil {
+		return
+	}
+	h.conn.Notify(
+		context.Background(),
+		"window/showMessage",
+		&ShowMessageParams{
+			Type:    typ,
+			Message: message,
+		})
+}
+
 func (h *langHandler) logMessage(typ MessageType, message string) {
+	if h.conn == nil {
+		// Not connected to a client when run from the command line.
+		h.logger.Println(message)
+		return
+	}
 	h.conn.Notify(
 		context.Background(),
 		"window/logMessage",
@@ -319,6 +504,10 @@ func (h *langHandler) linter() {
 			}
 		}()
 	}
+
+	for _, cancel := range running {
+		cancel()
+	}
 }
 
 func matchRootPath(fname string, markers []string) string {
@@ -356,8 +545,15 @@ func matchRootPath(fname string, markers []string) string {
 }
 
 func (h *langHandler) findRootPath(fname string, lang Language) string {
+	dir, _ := h.findRootPathReason(fname, lang)
+	return dir
+}
+
+// findRootPathReason is findRootPath, which also tells how the directory
+// was found.
+func (h *langHandler) findRootPathReason(fname string, lang Language) (string, string) {
 	if dir := matchRootPath(fname, lang.RootMarkers); dir != "" {
-		return dir
+		return dir, "root-markers of the tool"
 	}
 	h.mu.Lock()
 	rootMarkers := h.rootMarkers
@@ -365,16 +561,64 @@ func (h *langHandler) findRootPath(fname string, lang Language) string {
 	rootPath := h.rootPath
 	h.mu.Unlock()
 	if dir := matchRootPath(fname, rootMarkers); dir != "" {
-		return dir
+		return dir, "root-markers"
 	}
 
 	for _, folder := range folders {
 		if len(fname) > len(folder) && strings.EqualFold(fname[:len(folder)], folder) {
-			return folder
+			return folder, "workspace folder"
 		}
 	}
 
-	return rootPath
+	return rootPath, "root path of the client"
+}
+
+// requireMarkerSkipReason returns why the tool cfg, configured for the
+// language of fname, does not run for it, or "" when it does.
+func requireMarkerSkipReason(cfg Language, fname string) string {
+	if cfg.RequireMarker && matchRootPath(fname, cfg.RootMarkers) == "" {
+		return "require-marker is set and none of root-markers is found"
+	}
+	return ""
+}
+
+// lintSkipReason returns why the tool cfg, configured for the language of
+// fname, does not lint it on eventType, or "" when it does.
+func lintSkipReason(cfg Language, fname string, eventType eventType) string {
+	if reason := requireMarkerSkipReason(cfg, fname); reason != "" {
+		return reason
+	}
+	switch eventType {
+	case eventTypeOpen:
+		if !cfg.LintAfterOpen {
+			return "lint-after-open is not set"
+		}
+	case eventTypeChange:
+		if cfg.LintOnSave {
+			return "lint-on-save is set"
+		}
+	}
+	return ""
+}
+
+// lintCommandLine returns the command linting fname with config, and its
+// argv when it is run without a shell.
+func lintCommandLine(config Language, fname, rootPath string) (string, []string) {
+	if len(config.LintArgv) > 0 {
+		argv := withInput(config.LintArgv, config.LintStdin || config.LintWorkspace)
+		if config.LintTempfile {
+			argv = tempfileArgv(config.LintArgv)
+		}
+		argv = replaceArgvInputFilename(argv, fname, rootPath)
+		return strings.Join(argv, " "), argv
+	}
+	command := config.LintCommand
+	if config.LintTempfile {
+		command = tempfileCommand(command)
+	} else if !config.LintStdin && !config.LintWorkspace && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	return replaceCommandInputFilename(command, fname, rootPath), nil
 }
 
 func isFilename(s string) bool {
@@ -399,8 +643,10 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 	file := *f
 	loglevel := h.loglevel
 	logger := h.logger
-	langConfigs := append([]Language{}, h.configs[file.LanguageID]...)
-	wildcardConfigs := append([]Language{}, h.configs[wildcard]...)
+	enc := h.encoding()
+	cache := h.lintCache
+	langConfigs := append([]Language{}, h.languageConfigsLocked(uri, file.LanguageID)...)
+	wildcardConfigs := append([]Language{}, h.languageConfigsLocked(uri, wildcard)...)
 	h.mu.Unlock()
 
 	fname, err := fromURI(uri)
@@ -411,23 +657,9 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 
 	var configs []Language
 	for _, cfg := range langConfigs {
-		// if we require markers and find that they dont exist we do not add the configuration
-		if dir := matchRootPath(fname, cfg.RootMarkers); dir == "" && cfg.RequireMarker == true {
+		if lintSkipReason(cfg, fname, eventType) != "" {
 			continue
 		}
-		switch eventType {
-		case eventTypeOpen:
-			// if LintAfterOpen is not true, ignore didOpen
-			if !cfg.LintAfterOpen {
-				continue
-			}
-		case eventTypeChange:
-			// if LintOnSave is true, ignore didChange
-			if cfg.LintOnSave {
-				continue
-			}
-		default:
-		}
 		if cfg.LintCommand != "" {
 			configs = append(configs, cfg)
 		}
@@ -448,7 +680,9 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 	uriToDiagnostics := map[DocumentURI][]Diagnostic{
 		uri: {},
 	}
+	uriToFixes := map[DocumentURI][]quickFix{}
 	publishedURIs := make(map[DocumentURI]struct{})
+	texts := map[DocumentURI]string{uri: file.Text}
 	for i, config := range configs {
 		// To publish empty diagnostics when errors are fixed
 		if config.LintWorkspace {
@@ -465,37 +699,40 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			continue
 		}
 
-		command := config.LintCommand
-		if !config.LintStdin && !config.LintWorkspace && !strings.Contains(command, "${INPUT}") {
-			command = command + " ${INPUT}"
-		}
 		rootPath := h.findRootPath(fname, config)
-		command = replaceCommandInputFilename(command, fname, rootPath)
+		command, argv := lintCommandLine(config, fname, rootPath)
 
-		formats := config.LintFormats
-		if len(formats) == 0 {
-			formats = []string{"%f:%l:%m", "%f:%l:%c:%m"}
+		parse, err := newLintParser(&config)
+		if err != nil {
+			return nil, err
 		}
-
-		efms, err := errorformat.NewErrorformat(formats)
+		unit, err := columnUnitEncoding(config.LintColumnUnit)
 		if err != nil {
-			return nil, fmt.Errorf("invalid error-format: %v", config.LintFormats)
+			return nil, err
 		}
 
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.CommandContext(ctx, "cmd", "/c", command)
-		} else {
-			cmd = killableCommand(ctx, command)
+		c := shellCommand{
+			Command: command,
+			Argv:    argv,
+			Dir:     rootPath,
+			Env:     append(os.Environ(), config.Env...),
+			Timeout: time.Duration(config.LintTimeout),
+
+			Tool:        config.LintCommand,
+			MaxParallel: config.MaxParallel,
+			Priority:    priorityBackground,
+			URI:         uri,
 		}
-		cmd.Dir = rootPath
-		cmd.Env = append(os.Environ(), config.Env...)
 		if config.LintStdin {
-			cmd.Stdin = strings.NewReader(file.Text)
+			c.Stdin = strings.NewReader(file.Text)
+		}
+		b, err := h.lintOutput(ctx, cache, c, fname, file.Text, config)
+		if errors.Is(err, errCommandTimeout) || errors.Is(err, errCommandOutputLimit) {
+			continue
 		}
-		b, err := cmd.CombinedOutput()
 		if err != nil {
-			if succeeded(err) {
+			// Killed, or dropped from the queue, for a newer lint.
+			if succeeded(err) || ctx.Err() != nil {
 				return nil, nil
 			}
 		}
@@ -521,12 +758,12 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			prefix = fmt.Sprintf("[%s] ", config.Prefix)
 		}
 
-		scanner := efms.NewScanner(bytes.NewReader(b))
-		for scanner.Scan() {
-			entry := scanner.Entry()
-			if !entry.Valid {
-				continue
-			}
+		entries, err := parse(b)
+		if err != nil {
+			logger.Println(command+":", err)
+			continue
+		}
+		for _, entry := range entries {
 			if config.LintStdin && isFilename(entry.Filename) {
 				entry.Filename = fname
 				path, err := filepath.Abs(entry.Filename)
@@ -542,8 +779,6 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			} else {
 				entry.Filename = filepath.ToSlash(entry.Filename)
 			}
-			word := ""
-
 			// entry.Col is expected to be one based, if the linter returns zero based we
 			// have the ability to add an offset here.
 			// We only add the offset if the linter reports entry.Col > 0 because 0 means the whole line
@@ -560,15 +795,14 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 				entry.Lnum = 1 // entry.Lnum == 0 indicates the top line, set to 1 because it is subtracted later
 			}
 
-			if entry.Col == 0 {
+			wholeLine := entry.Col == 0
+			if wholeLine {
 				entry.Col = 1 // entry.Col == 0 indicates the whole line without column, set to 1 because it is subtracted later
-			} else {
-				word = file.WordAt(Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1})
 			}
 
 			// we allow the config to provide a mapping between LSP types E,W,I,N and whatever categories the linter has
 			if len(config.LintCategoryMap) > 0 {
-				if mapped, ok := config.LintCategoryMap[string(entry.Type)]; ok && mapped != "" {
+				if mapped, ok := config.LintCategoryMap[entry.Category]; ok && mapped != "" {
 					entry.Type = []rune(mapped)[0]
 				}
 			}
@@ -613,8 +847,18 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			if config.LintWorkspace {
 				publishedURIs[diagURI] = struct{}{}
 			}
+			text, ok := texts[diagURI]
+			if !ok {
+				text = h.documentText(diagURI)
+				texts[diagURI] = text
+			}
+			word := ""
+			if !wholeLine {
+				line := entry.Lnum - 1 - config.LintOffset
+				word = (&File{Text: text}).WordAt(Position{Line: line, Character: convertCharacter(lineAt(text, line), entry.Col-1, unit, PositionEncodingUTF16)})
+			}
 			start := Position{Line: entry.Lnum - 1 - config.LintOffset, Character: entry.Col - 1}
-			end := Position{Line: start.Line, Character: entry.Col - 1 + len([]rune(word))}
+			end := Position{Line: start.Line, Character: entry.Col - 1 + encodedLen(word, unit)}
 			// %e (end line) and %k (end column, exclusive like eslint's
 			// endColumn) take precedence over the word-based guess.
 			if entry.EndLnum > 0 {
@@ -623,23 +867,48 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 			if entry.EndCol > 0 {
 				end.Character = entry.EndCol - 1
 			}
+			// Columns count in the unit of the linter until converted against
+			// the text of the file they point into.
+			start.Character = convertCharacter(lineAt(text, start.Line), start.Character, unit, enc)
+			end.Character = convertCharacter(lineAt(text, end.Line), end.Character, unit, enc)
 			if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
 				end = start
 			}
-			uriToDiagnostics[diagURI] = append(uriToDiagnostics[diagURI], Diagnostic{
+			diagnostic := Diagnostic{
 				Range: Range{
 					Start: start,
 					End:   end,
 				},
-				Code:     itoaPtrIfNotZero(entry.Nr),
+				Code:     stringPtrIfNotEmpty(entry.Code),
 				Message:  prefix + entry.Text,
 				Severity: severity,
 				Source:   source,
-			})
+			}
+			uriToDiagnostics[diagURI] = append(uriToDiagnostics[diagURI], diagnostic)
+
+			for _, fix := range entry.Fixes {
+				title := fix.Title
+				if title == "" {
+					title = "Fix: " + entry.Text
+				}
+				uriToFixes[diagURI] = append(uriToFixes[diagURI], quickFix{
+					Diagnostic: diagnostic,
+					Title:      title,
+					Edits:      lintFixEdits(text, fix, config.LintOffset, config.LintOffsetColumns, unit, enc),
+				})
+			}
 		}
 	}
 
 	// Update state here as no possibility of cancelation
+	h.mu.Lock()
+	if h.quickFixes == nil {
+		h.quickFixes = make(map[DocumentURI][]quickFix)
+	}
+	for diagURI := range uriToDiagnostics {
+		h.quickFixes[diagURI] = uriToFixes[diagURI]
+	}
+	h.mu.Unlock()
 	for _, config := range configs {
 		if config.LintWorkspace {
 			h.mu.Lock()
@@ -651,22 +920,52 @@ func (h *langHandler) lint(ctx context.Context, uri DocumentURI, eventType event
 	return uriToDiagnostics, nil
 }
 
-func itoaPtrIfNotZero(n int) *string {
-	if n == 0 {
+// documentText returns the text of uri as the client sees it: the open
+// document if there is one, otherwise the file on disk.
+func (h *langHandler) documentText(uri DocumentURI) string {
+	h.mu.Lock()
+	f, ok := h.files[uri]
+	var text string
+	if ok {
+		text = f.Text
+	}
+	h.mu.Unlock()
+	if ok {
+		return text
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return ""
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func stringPtrIfNotEmpty(s string) *string {
+	if s == "" {
 		return nil
 	}
-	s := strconv.Itoa(n)
 	return &s
 }
 
 func (h *langHandler) closeFile(uri DocumentURI) error {
 	h.mu.Lock()
 	delete(h.files, uri)
+	delete(h.quickFixes, uri)
+	delete(h.fixDiffs, uri)
+	delete(h.pullEvents, uri)
+	delete(h.pullChanged, uri)
+	delete(h.diagnosticResults, uri)
+	h.workspaceChangedLocked()
 	h.mu.Unlock()
 	return nil
 }
 
 func (h *langHandler) saveFile(uri DocumentURI) error {
+	h.executor.setActive(uri)
 	h.lintRequest(uri, eventTypeSave)
 	return nil
 }
@@ -680,6 +979,7 @@ func (h *langHandler) openFile(uri DocumentURI, languageID string, version int)
 	h.mu.Lock()
 	h.files[uri] = f
 	h.mu.Unlock()
+	h.discoverProject(uri)
 	return nil
 }
 
@@ -696,6 +996,11 @@ func (h *langHandler) updateFile(uri DocumentURI, text string, version *int, eve
 	}
 	h.mu.Unlock()
 
+	// Documents being opened, e.g. along with a session, are linted after
+	// the one being edited.
+	if eventType != eventTypeOpen {
+		h.executor.setActive(uri)
+	}
 	h.lintRequest(uri, eventType)
 	return nil
 }
@@ -733,7 +1038,7 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 	case "initialize":
 		return h.handleInitialize(ctx, conn, req)
 	case "initialized":
-		return
+		return h.handleInitialized(ctx, conn, req)
 	case "shutdown":
 		return h.handleShutdown(ctx, conn, req)
 	case "textDocument/didOpen":
@@ -748,6 +1053,10 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 		return h.handleTextDocumentFormatting(ctx, conn, req)
 	case "textDocument/rangeFormatting":
 		return h.handleTextDocumentRangeFormatting(ctx, conn, req)
+	case "textDocument/willSaveWaitUntil":
+		return h.handleTextDocumentWillSaveWaitUntil(ctx, conn, req)
+	case "textDocument/onTypeFormatting":
+		return h.handleTextDocumentOnTypeFormatting(ctx, conn, req)
 	case "textDocument/documentSymbol":
 		return h.handleTextDocumentSymbol(ctx, conn, req)
 	case "textDocument/completion":
@@ -758,14 +1067,20 @@ func (h *langHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *json
 		return h.handleTextDocumentHover(ctx, conn, req)
 	case "textDocument/codeAction":
 		return h.handleTextDocumentCodeAction(ctx, conn, req)
+	case "$/cancelRequest":
+		return h.handleCancelRequest(ctx, conn, req)
 	case "workspace/executeCommand":
 		return h.handleWorkspaceExecuteCommand(ctx, conn, req)
+	case "workspace/didChangeWatchedFiles":
+		return h.handleWorkspaceDidChangeWatchedFiles(ctx, conn, req)
 	case "workspace/didChangeConfiguration":
 		return h.handleWorkspaceDidChangeConfiguration(ctx, conn, req)
 	case "workspace/didChangeWorkspaceFolders":
 		return h.handleDidChangeWorkspaceWorkspaceFolders(ctx, conn, req)
 	case "workspace/workspaceFolders":
 		return h.handleWorkspaceWorkspaceFolders(ctx, conn, req)
+	case "efm-langserver/explain":
+		return h.handleExplain(ctx, conn, req)
 	}
 
 	// LSP requires servers to ignore notifications they do not handle
@@ -7,7 +7,12 @@ import (
 	"os/exec"
 )
 
-func killableCommand(_ context.Context, _ string) *exec.Cmd {
+// killable leaves killing the children of cmd to the operating system.
+func killable(cmd *exec.Cmd) *exec.Cmd {
+	return cmd
+}
+
+func killableCommand(_ context.Context, _ string, _ ...string) *exec.Cmd {
 	panic("killableCommand() should not be called from non-unix systems")
 	// TODO: There may be a Windows-equivalent implementation for the unix one,
 	// but I'll leave that to a Windows user to implement and test. :)
@@ -13,8 +13,13 @@ import (
 // killableComand configures a command so that it *and* all of its children will be killed when
 // 'ctx' is cancelled.
 // See: https://medium.com/@felixge/killing-a-child-process-and-all-of-its-children-in-go-54079af94773
-func killableCommand(ctx context.Context, command string) *exec.Cmd {
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+func killableCommand(ctx context.Context, command string, args ...string) *exec.Cmd {
+	return killable(exec.CommandContext(ctx, "sh", append([]string{"-c", command}, args...)...))
+}
+
+// killable configures a command created with exec.CommandContext() the same
+// way as killableCommand().
+func killable(cmd *exec.Cmd) *exec.Cmd {
 
 	// By default, exec.CommandContext() sets Cancel() to only kill the main process.
 	// (In this case, `sh`.)
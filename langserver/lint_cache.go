@@ -0,0 +1,275 @@
+package langserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lintCacheMemory = "memory"
+	lintCacheDisk   = "disk"
+	lintCacheOff    = "off"
+
+	// lintCacheEntries is how many lint results are kept in memory.
+	lintCacheEntries = 512
+	// lintCacheMaxAge is how long lint results are kept on disk.
+	lintCacheMaxAge = 7 * 24 * time.Hour
+)
+
+// lintResult is what a lint command printed, and how it exited.
+type lintResult struct {
+	Output   []byte `json:"output"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// err returns the error the command returned when it was run.
+func (r *lintResult) err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	return fmt.Errorf("exit status %d (cached)", r.ExitCode)
+}
+
+// lintCache keeps the results of lint commands, so that a document linted
+// again with the same text, command and tool is not linted by running the
+// tool again. Results are also written to dir when it is not empty.
+type lintCache struct {
+	mu      sync.Mutex
+	entries map[string]*lintResult
+	order   []string
+	dir     string
+	// since is when the cache was last invalidated. Results written to dir
+	// before are not used.
+	since time.Time
+}
+
+// newLintCache returns the cache for the lint-cache setting, or nil when
+// caching is off, which is the default.
+func newLintCache(mode string) (*lintCache, error) {
+	c := &lintCache{entries: make(map[string]*lintResult)}
+	switch mode {
+	case lintCacheMemory:
+	case lintCacheDisk:
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		c.dir = filepath.Join(dir, "efm-langserver", "lint")
+		if err := os.MkdirAll(c.dir, 0o700); err != nil {
+			return nil, err
+		}
+		go c.prune()
+	case "", lintCacheOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid lint-cache: %q", mode)
+	}
+	return c, nil
+}
+
+func (c *lintCache) get(key string) (*lintResult, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	r, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok || c.dir == "" {
+		return r, ok
+	}
+
+	c.mu.Lock()
+	since := c.since
+	c.mu.Unlock()
+	name := filepath.Join(c.dir, key)
+	if info, err := os.Stat(name); err != nil || info.ModTime().Before(since) {
+		return nil, false
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	r = &lintResult{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, false
+	}
+	c.add(key, r)
+	return r, true
+}
+
+func (c *lintCache) put(key string, r *lintResult) {
+	if c == nil || key == "" {
+		return
+	}
+	c.add(key, r)
+	if c.dir == "" {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	// Write and rename, so that another server reading the same cache does
+	// not see a partial entry.
+	tmp, err := os.CreateTemp(c.dir, key+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *lintCache) add(key string, r *lintResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = r
+	for len(c.order) > lintCacheEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// invalidate drops the results cached so far, e.g. when files linters may
+// read besides the document changed.
+func (c *lintCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*lintResult)
+	c.order = nil
+	c.since = time.Now()
+}
+
+// prune removes the results written to disk long ago.
+func (c *lintCache) prune() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > lintCacheMaxAge {
+			os.Remove(filepath.Join(c.dir, entry.Name()))
+		}
+	}
+}
+
+// lintCacheKey returns the key of the result of running c for fname, which
+// changes with the text the tool reads, the expanded command, its
+// environment, the executable of the tool and the files in the directory it
+// runs in, such as its configuration files. Files in subdirectories and
+// other files the tool reads are not part of the key, which is why caching is
+// opt-in. It returns "" when any of them can not be known, so that the result
+// is not cached.
+func lintCacheKey(c shellCommand, fname, text string, stdin bool, env []string) string {
+	if !stdin {
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			return ""
+		}
+		text = string(b)
+	}
+
+	name := c.Command
+	if len(c.Argv) > 0 {
+		name = c.Argv[0]
+	} else if fields := strings.Fields(name); len(fields) > 0 {
+		name = fields[0]
+	}
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(c.Dir, name)
+		}
+	} else if path, err := exec.LookPath(name); err == nil {
+		name = path
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	write := func(s string) {
+		fmt.Fprintf(hash, "%d:%s", len(s), s)
+	}
+	write(text)
+	write(fmt.Sprint(stdin))
+	write(c.Command)
+	write(strings.Join(c.Argv, "\x00"))
+	write(c.Dir)
+	write(os.Getenv("PATH"))
+	write(strings.Join(env, "\x00"))
+	write(name)
+	write(fmt.Sprint(info.Size(), info.ModTime().UnixNano()))
+	if entries, err := os.ReadDir(c.Dir); err == nil {
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+				write(fmt.Sprint(entry.Name(), info.Size(), info.ModTime().UnixNano()))
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// lintOutput is output for lint commands, returning the cached result of c
+// when there is one instead of running it again. Results of lint-workspace
+// tools depend on the whole workspace, so they are never cached.
+func (h *langHandler) lintOutput(ctx context.Context, cache *lintCache, c shellCommand, fname, text string, config Language) ([]byte, error) {
+	if config.LintWorkspace {
+		cache = nil
+	}
+	var key string
+	if cache != nil {
+		key = lintCacheKey(c, fname, text, config.LintStdin || config.LintTempfile, config.Env)
+	}
+	if r, ok := cache.get(key); ok {
+		return r.Output, r.err()
+	}
+
+	var b []byte
+	var err error
+	if config.LintTempfile {
+		b, _, err = withTempfile(c, fname, text, func(c shellCommand, _ string) ([]byte, []byte, error) {
+			b, err := h.output(ctx, c)
+			return b, nil, err
+		})
+	} else {
+		b, err = h.output(ctx, c)
+	}
+	// Commands killed or dropped for a newer lint, or which timed out, did
+	// not finish linting.
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		cache.put(key, &lintResult{Output: b})
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && ctx.Err() == nil:
+		cache.put(key, &lintResult{Output: b, ExitCode: exitErr.ExitCode()})
+	}
+	return b, err
+}
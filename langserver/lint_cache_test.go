@@ -0,0 +1,135 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLintCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell redirect")
+	}
+	root := t.TempDir()
+	count := filepath.Join(t.TempDir(), "count")
+	uri := toURI(filepath.Join(root, "foo.vim"))
+
+	for _, disk := range []bool{false, true} {
+		os.Remove(count)
+		cache := &lintCache{entries: make(map[string]*lintResult)}
+		if disk {
+			cache.dir = t.TempDir()
+		}
+		h := &langHandler{
+			logger:    log.New(log.Writer(), "", log.LstdFlags),
+			rootPath:  root,
+			lintCache: cache,
+			configs: map[string][]Language{
+				"vim": {
+					{
+						LintCommand: `echo x >> ` + count + `; echo stdin:1:msg; exit 1`,
+						LintStdin:   true,
+					},
+				},
+			},
+			files: map[DocumentURI]*File{
+				uri: {
+					LanguageID: "vim",
+					Text:       "a\n",
+				},
+			},
+		}
+
+		lint := func() {
+			t.Helper()
+			d, err := h.lint(context.Background(), uri, eventTypeSave)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d[uri]) != 1 || d[uri][0].Message != "msg" {
+				t.Fatalf("cached lint should report the same diagnostics but got: %v", d)
+			}
+		}
+		runs := func() int {
+			b, _ := os.ReadFile(count)
+			return strings.Count(string(b), "x")
+		}
+
+		lint()
+		lint()
+		if n := runs(); n != 1 {
+			t.Fatalf("linting the same text again should not run the linter but it ran %d times", n)
+		}
+
+		if disk {
+			// Another server reading the same directory.
+			h.lintCache = &lintCache{entries: make(map[string]*lintResult), dir: cache.dir}
+			lint()
+			if n := runs(); n != 1 {
+				t.Fatalf("results on disk should be used but the linter ran %d times", n)
+			}
+		}
+
+		h.files[uri].Text = "b\n"
+		lint()
+		if n := runs(); n != 2 {
+			t.Fatalf("linting a changed text should run the linter but it ran %d times", n)
+		}
+	}
+}
+
+func TestLintCacheOffByDefault(t *testing.T) {
+	cache, err := newLintCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache != nil {
+		t.Fatal("lint results should not be cached by default")
+	}
+}
+
+func TestLintCacheSkipsWorkspaceTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell redirect")
+	}
+	root := t.TempDir()
+	count := filepath.Join(t.TempDir(), "count")
+	uri := toURI(filepath.Join(root, "foo.vim"))
+
+	h := &langHandler{
+		logger:    log.New(log.Writer(), "", log.LstdFlags),
+		rootPath:  root,
+		lintCache: &lintCache{entries: make(map[string]*lintResult)},
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo x >> ` + count + `; echo foo.vim:1:msg`,
+					LintFormats:        []string{"%f:%l:%m"},
+					LintIgnoreExitCode: true,
+					LintWorkspace:      true,
+				},
+			},
+		},
+		lastPublishedURIs: make(map[string]map[DocumentURI]struct{}),
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "a\n",
+			},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.lint(context.Background(), uri, eventTypeSave); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := os.ReadFile(count)
+	if n := strings.Count(string(b), "x"); n != 2 {
+		t.Fatalf("lint-workspace tools should run every time but ran %d times", n)
+	}
+}
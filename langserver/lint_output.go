@@ -0,0 +1,591 @@
+package langserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/reviewdog/errorformat"
+)
+
+// Supported values for lint-output-format.
+const (
+	lintOutputErrorformat = "errorformat"
+	lintOutputSARIF       = "sarif"
+	lintOutputCheckstyle  = "checkstyle"
+	lintOutputRDJSON      = "rdjson"
+	lintOutputRDJSONL     = "rdjsonl"
+	lintOutputJSONPath    = "json-path"
+)
+
+// LintJSONPath describes where to find the fields of a diagnostic in an
+// arbitrary JSON document. Paths are separated by dots, `[*]` iterates over
+// an array and `[N]` selects an element. Field paths are relative to each
+// item; a leading `^` steps out to the object enclosing it, e.g.
+// `^.filePath` for eslint's `[*].messages[*]`.
+type LintJSONPath struct {
+	Items     string `yaml:"items" json:"items"`
+	File      string `yaml:"file" json:"file"`
+	Line      string `yaml:"line" json:"line"`
+	Column    string `yaml:"column" json:"column"`
+	EndLine   string `yaml:"end-line" json:"endLine"`
+	EndColumn string `yaml:"end-column" json:"endColumn"`
+	Severity  string `yaml:"severity" json:"severity"`
+	Code      string `yaml:"code" json:"code"`
+	Message   string `yaml:"message" json:"message"`
+
+	// FixEdits points to the list of edits fixing the item, for example
+	// `fix.edits` for ruff. The other Fix* paths are relative to each edit.
+	FixEdits     string `yaml:"fix-edits" json:"fixEdits"`
+	FixLine      string `yaml:"fix-line" json:"fixLine"`
+	FixColumn    string `yaml:"fix-column" json:"fixColumn"`
+	FixEndLine   string `yaml:"fix-end-line" json:"fixEndLine"`
+	FixEndColumn string `yaml:"fix-end-column" json:"fixEndColumn"`
+	FixText      string `yaml:"fix-text" json:"fixText"`
+}
+
+// lintEntry is a single finding reported by a linter, independent of the
+// format it was parsed from. Lines and columns are one based and EndCol is
+// exclusive, the same as errorformat's %e and %k.
+type lintEntry struct {
+	Filename string
+	Lnum     int
+	Col      int
+	EndLnum  int
+	EndCol   int
+	Type     rune
+	Category string
+	Code     string
+	Text     string
+	Fixes    []lintFix
+}
+
+// lintFix is a fix suggested by a linter for a lintEntry.
+type lintFix struct {
+	Title string
+	Edits []lintEdit
+}
+
+// lintEdit replaces a range, using the same conventions as lintEntry. An
+// EndLnum of zero means Lnum, and an EndCol of zero extends the range to the
+// end of EndLnum.
+type lintEdit struct {
+	Lnum    int
+	Col     int
+	EndLnum int
+	EndCol  int
+	Text    string
+}
+
+type lintParser func(b []byte) ([]lintEntry, error)
+
+// newLintParser returns the parser for config's lint-output-format. It is
+// created before running the linter so invalid settings are reported without
+// executing anything.
+func newLintParser(config *Language) (lintParser, error) {
+	switch config.LintOutputFormat {
+	case "", lintOutputErrorformat:
+		formats := config.LintFormats
+		if len(formats) == 0 {
+			formats = []string{"%f:%l:%m", "%f:%l:%c:%m"}
+		}
+		efms, err := newErrorformat(formats)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error-format: %v", config.LintFormats)
+		}
+		return func(b []byte) ([]lintEntry, error) {
+			return parseErrorformat(efms, b), nil
+		}, nil
+	case lintOutputSARIF:
+		return parseSARIF, nil
+	case lintOutputCheckstyle:
+		return parseCheckstyle, nil
+	case lintOutputRDJSON:
+		return parseRDJSON, nil
+	case lintOutputRDJSONL:
+		return parseRDJSONL, nil
+	case lintOutputJSONPath:
+		if config.LintJSONPath == nil || config.LintJSONPath.Line == "" || config.LintJSONPath.Message == "" {
+			return nil, fmt.Errorf("lint-json-path requires at least line and message: %v", config.LintCommand)
+		}
+		paths := *config.LintJSONPath
+		return func(b []byte) ([]lintEntry, error) {
+			return parseJSONPath(&paths, b)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid lint-output-format: %v", config.LintOutputFormat)
+	}
+}
+
+// newErrorformat compiles formats, some malformed ones of which make
+// errorformat panic.
+func newErrorformat(formats []string) (efms *errorformat.Errorformat, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return errorformat.NewErrorformat(formats)
+}
+
+func parseErrorformat(efms *errorformat.Errorformat, b []byte) []lintEntry {
+	var entries []lintEntry
+	scanner := efms.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		entry := scanner.Entry()
+		if !entry.Valid {
+			continue
+		}
+		var code string
+		if entry.Nr != 0 {
+			code = strconv.Itoa(entry.Nr)
+		}
+		entries = append(entries, lintEntry{
+			Filename: entry.Filename,
+			Lnum:     entry.Lnum,
+			Col:      entry.Col,
+			EndLnum:  entry.EndLnum,
+			EndCol:   entry.EndCol,
+			Type:     entry.Type,
+			Category: string(entry.Type),
+			Code:     code,
+			Text:     entry.Text,
+		})
+	}
+	return entries
+}
+
+// severityType maps the severity names used by the structured formats to
+// the E/W/I/N types errorformat produces.
+func severityType(s string) rune {
+	switch strings.ToLower(s) {
+	case "error", "fatal", "critical":
+		return 'E'
+	case "warning", "warn":
+		return 'W'
+	case "info", "information", "note":
+		return 'I'
+	case "hint", "style":
+		return 'N'
+	}
+	return 0
+}
+
+// uriToFilename converts the file references found in SARIF and similar
+// formats, which may be either file URIs or (percent-encoded) paths.
+func uriToFilename(s string) string {
+	if strings.HasPrefix(s, "file:") {
+		if fname, err := fromURI(DocumentURI(s)); err == nil {
+			return fname
+		}
+	}
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+type sarifLog struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+					Region           sarifRegion           `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+			Fixes []struct {
+				Description struct {
+					Text string `json:"text"`
+				} `json:"description"`
+				ArtifactChanges []struct {
+					ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+					Replacements     []struct {
+						DeletedRegion   sarifRegion `json:"deletedRegion"`
+						InsertedContent struct {
+							Text string `json:"text"`
+						} `json:"insertedContent"`
+					} `json:"replacements"`
+				} `json:"artifactChanges"`
+			} `json:"fixes"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func parseSARIF(b []byte) ([]lintEntry, error) {
+	var log sarifLog
+	if err := json.Unmarshal(b, &log); err != nil {
+		return nil, fmt.Errorf("invalid sarif output: %v", err)
+	}
+	var entries []lintEntry
+	for _, run := range log.Runs {
+		for _, result := range run.Results {
+			entry := lintEntry{
+				Type:     severityType(result.Level),
+				Category: result.Level,
+				Code:     result.RuleID,
+				Text:     result.Message.Text,
+			}
+			if len(result.Locations) > 0 {
+				loc := result.Locations[0].PhysicalLocation
+				entry.Filename = uriToFilename(loc.ArtifactLocation.URI)
+				entry.Lnum = loc.Region.StartLine
+				entry.Col = loc.Region.StartColumn
+				entry.EndLnum = loc.Region.EndLine
+				entry.EndCol = loc.Region.EndColumn
+			}
+			for _, fix := range result.Fixes {
+				var edits []lintEdit
+				for _, change := range fix.ArtifactChanges {
+					if uriToFilename(change.ArtifactLocation.URI) != entry.Filename {
+						continue
+					}
+					for _, r := range change.Replacements {
+						edits = append(edits, lintEdit{
+							Lnum:    r.DeletedRegion.StartLine,
+							Col:     r.DeletedRegion.StartColumn,
+							EndLnum: r.DeletedRegion.EndLine,
+							EndCol:  r.DeletedRegion.EndColumn,
+							Text:    r.InsertedContent.Text,
+						})
+					}
+				}
+				if len(edits) > 0 {
+					entry.Fixes = append(entry.Fixes, lintFix{Title: fix.Description.Text, Edits: edits})
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+type checkstyleResult struct {
+	Files []struct {
+		Name   string `xml:"name,attr"`
+		Errors []struct {
+			Line     int    `xml:"line,attr"`
+			Column   int    `xml:"column,attr"`
+			Severity string `xml:"severity,attr"`
+			Message  string `xml:"message,attr"`
+			Source   string `xml:"source,attr"`
+		} `xml:"error"`
+	} `xml:"file"`
+}
+
+func parseCheckstyle(b []byte) ([]lintEntry, error) {
+	var result checkstyleResult
+	if err := xml.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("invalid checkstyle output: %v", err)
+	}
+	var entries []lintEntry
+	for _, file := range result.Files {
+		for _, e := range file.Errors {
+			entries = append(entries, lintEntry{
+				Filename: file.Name,
+				Lnum:     e.Line,
+				Col:      e.Column,
+				Type:     severityType(e.Severity),
+				Category: e.Severity,
+				Code:     e.Source,
+				Text:     e.Message,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// rdjsonDiagnostic is a diagnostic in reviewdog's Diagnostic Format.
+// https://github.com/reviewdog/reviewdog/tree/master/proto/rdf
+type rdjsonDiagnostic struct {
+	Message  string `json:"message"`
+	Location struct {
+		Path  string      `json:"path"`
+		Range rdjsonRange `json:"range"`
+	} `json:"location"`
+	Severity string `json:"severity"`
+	Code     struct {
+		Value string `json:"value"`
+	} `json:"code"`
+	Suggestions []struct {
+		Range rdjsonRange `json:"range"`
+		Text  string      `json:"text"`
+	} `json:"suggestions"`
+}
+
+type rdjsonRange struct {
+	Start struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"start"`
+	End struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"end"`
+}
+
+func (d *rdjsonDiagnostic) entry() lintEntry {
+	var fixes []lintFix
+	for _, s := range d.Suggestions {
+		fixes = append(fixes, lintFix{Edits: []lintEdit{{
+			Lnum:    s.Range.Start.Line,
+			Col:     s.Range.Start.Column,
+			EndLnum: s.Range.End.Line,
+			EndCol:  s.Range.End.Column,
+			Text:    s.Text,
+		}}})
+	}
+	return lintEntry{
+		Filename: d.Location.Path,
+		Lnum:     d.Location.Range.Start.Line,
+		Col:      d.Location.Range.Start.Column,
+		EndLnum:  d.Location.Range.End.Line,
+		EndCol:   d.Location.Range.End.Column,
+		Type:     severityType(d.Severity),
+		Category: d.Severity,
+		Code:     d.Code.Value,
+		Text:     d.Message,
+		Fixes:    fixes,
+	}
+}
+
+func parseRDJSON(b []byte) ([]lintEntry, error) {
+	var result struct {
+		Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("invalid rdjson output: %v", err)
+	}
+	var entries []lintEntry
+	for i := range result.Diagnostics {
+		entries = append(entries, result.Diagnostics[i].entry())
+	}
+	return entries, nil
+}
+
+func parseRDJSONL(b []byte) ([]lintEntry, error) {
+	var entries []lintEntry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var d rdjsonDiagnostic
+		if err := json.Unmarshal(line, &d); err != nil {
+			return nil, fmt.Errorf("invalid rdjsonl output: %v", err)
+		}
+		entries = append(entries, d.entry())
+	}
+	return entries, scanner.Err()
+}
+
+// jsonPathItem is a value selected by LintJSONPath.Items together with the
+// items enclosing it, innermost last.
+type jsonPathItem struct {
+	value   any
+	parents []any
+}
+
+func parseJSONPath(paths *LintJSONPath, b []byte) ([]lintEntry, error) {
+	var root any
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("invalid json output: %v", err)
+	}
+	var entries []lintEntry
+	for _, item := range jsonPathSelect(root, paths.Items) {
+		severity := jsonPathString(item, paths.Severity)
+		entries = append(entries, lintEntry{
+			Filename: jsonPathString(item, paths.File),
+			Lnum:     jsonPathInt(item, paths.Line),
+			Col:      jsonPathInt(item, paths.Column),
+			EndLnum:  jsonPathInt(item, paths.EndLine),
+			EndCol:   jsonPathInt(item, paths.EndColumn),
+			Type:     severityType(severity),
+			Category: severity,
+			Code:     jsonPathString(item, paths.Code),
+			Text:     jsonPathString(item, paths.Message),
+			Fixes:    jsonPathFixes(item, paths),
+		})
+	}
+	return entries, nil
+}
+
+func jsonPathFixes(item jsonPathItem, paths *LintJSONPath) []lintFix {
+	if paths.FixEdits == "" {
+		return nil
+	}
+	v, ok := jsonPathValue(item, paths.FixEdits)
+	if !ok {
+		return nil
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	var edits []lintEdit
+	for _, e := range arr {
+		edit := jsonPathItem{value: e}
+		edits = append(edits, lintEdit{
+			Lnum:    jsonPathInt(edit, paths.FixLine),
+			Col:     jsonPathInt(edit, paths.FixColumn),
+			EndLnum: jsonPathInt(edit, paths.FixEndLine),
+			EndCol:  jsonPathInt(edit, paths.FixEndColumn),
+			Text:    jsonPathString(edit, paths.FixText),
+		})
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	return []lintFix{{Edits: edits}}
+}
+
+// splitJSONPath splits a path such as `$.runs[*].results[0]` into segments
+// like `runs`, `[*]`, `results`, `[0]`.
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				segments = append(segments, part)
+				break
+			}
+			if i > 0 {
+				segments = append(segments, part[:i])
+			}
+			j := strings.Index(part[i:], "]")
+			if j < 0 {
+				segments = append(segments, part[i:])
+				break
+			}
+			segments = append(segments, part[i:i+j+1])
+			part = part[i+j+1:]
+		}
+	}
+	return segments
+}
+
+func jsonPathSelect(root any, path string) []jsonPathItem {
+	items := []jsonPathItem{{value: root}}
+	for _, segment := range splitJSONPath(path) {
+		var next []jsonPathItem
+		for _, item := range items {
+			parents := append(append([]any{}, item.parents...), item.value)
+			if segment != "[*]" {
+				if v, ok := jsonPathStep(item.value, segment); ok {
+					next = append(next, jsonPathItem{value: v, parents: parents})
+				}
+				continue
+			}
+			arr, ok := item.value.([]any)
+			if !ok {
+				continue
+			}
+			for _, v := range arr {
+				next = append(next, jsonPathItem{value: v, parents: parents})
+			}
+		}
+		items = next
+	}
+	return items
+}
+
+func jsonPathStep(v any, segment string) (any, bool) {
+	if strings.HasPrefix(segment, "[") && strings.HasSuffix(segment, "]") {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, false
+		}
+		i, err := strconv.Atoi(segment[1 : len(segment)-1])
+		if err != nil || i < 0 || i >= len(arr) {
+			return nil, false
+		}
+		return arr[i], true
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok = obj[segment]
+	return v, ok
+}
+
+func jsonPathValue(item jsonPathItem, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	v := item.value
+	parents := item.parents
+	for strings.HasPrefix(path, "^") {
+		path = path[1:]
+		// Skip over the arrays that enclose the item.
+		for len(parents) > 0 {
+			v = parents[len(parents)-1]
+			parents = parents[:len(parents)-1]
+			if _, ok := v.([]any); !ok {
+				break
+			}
+		}
+	}
+	for _, segment := range splitJSONPath(path) {
+		var ok bool
+		if v, ok = jsonPathStep(v, segment); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func jsonPathString(item jsonPathItem, path string) string {
+	v, ok := jsonPathValue(item, path)
+	if !ok || v == nil {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func jsonPathInt(item jsonPathItem, path string) int {
+	v, ok := jsonPathValue(item, path)
+	if !ok {
+		return 0
+	}
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"testing"
+)
+
+func TestParseSARIF(t *testing.T) {
+	output := `{
+  "version": "2.1.0",
+  "runs": [{
+    "results": [{
+      "ruleId": "DL3006",
+      "level": "warning",
+      "message": {"text": "Always tag the version of an image explicitly"},
+      "locations": [{
+        "physicalLocation": {
+          "artifactLocation": {"uri": "docker/Dockerfile%20dev"},
+          "region": {"startLine": 1, "startColumn": 1, "endLine": 1, "endColumn": 12}
+        }
+      }]
+    }]
+  }]
+}`
+	entries, err := parseSARIF([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries should be only one but got: %v", entries)
+	}
+	e := entries[0]
+	if e.Filename != "docker/Dockerfile dev" {
+		t.Fatalf("filename should be %q but got: %q", "docker/Dockerfile dev", e.Filename)
+	}
+	if e.Lnum != 1 || e.Col != 1 || e.EndLnum != 1 || e.EndCol != 12 {
+		t.Fatalf("unexpected position: %+v", e)
+	}
+	if e.Type != 'W' || e.Code != "DL3006" {
+		t.Fatalf("type should be W and code DL3006 but got: %c %q", e.Type, e.Code)
+	}
+}
+
+func TestParseCheckstyle(t *testing.T) {
+	output := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+  <file name="script.sh">
+    <error line="3" column="6" severity="error" message="Double quote to prevent globbing" source="ShellCheck.SC2086"/>
+    <error line="7" column="1" severity="info" message="Not following" source="ShellCheck.SC1091"/>
+  </file>
+</checkstyle>`
+	entries, err := parseCheckstyle([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries should be two but got: %v", entries)
+	}
+	if entries[0].Filename != "script.sh" || entries[0].Lnum != 3 || entries[0].Col != 6 {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+	if entries[0].Type != 'E' || entries[1].Type != 'I' {
+		t.Fatalf("types should be E and I but got: %c %c", entries[0].Type, entries[1].Type)
+	}
+	if entries[0].Code != "ShellCheck.SC2086" {
+		t.Fatalf("code should be %q but got: %q", "ShellCheck.SC2086", entries[0].Code)
+	}
+}
+
+func TestParseRDJSONL(t *testing.T) {
+	output := `{"message":"unused variable","location":{"path":"main.py","range":{"start":{"line":2,"column":5},"end":{"line":2,"column":8}}},"severity":"ERROR","code":{"value":"F841"}}
+
+{"message":"line too long","location":{"path":"main.py","range":{"start":{"line":4}}},"severity":"WARNING"}
+`
+	entries, err := parseRDJSONL([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries should be two but got: %v", entries)
+	}
+	if entries[0].Col != 5 || entries[0].EndCol != 8 || entries[0].Code != "F841" || entries[0].Type != 'E' {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+	if entries[1].Lnum != 4 || entries[1].Col != 0 || entries[1].Type != 'W' {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	// Output of `eslint -f json`.
+	output := `[{
+  "filePath": "/src/index.js",
+  "messages": [
+    {"ruleId": "no-unused-vars", "severity": 2, "message": "'a' is unused.", "line": 1, "column": 7, "endLine": 1, "endColumn": 8},
+    {"ruleId": "semi", "severity": 1, "message": "Missing semicolon.", "line": 3, "column": 10}
+  ]
+}]`
+	paths := &LintJSONPath{
+		Items:     "[*].messages[*]",
+		File:      "^.filePath",
+		Line:      "line",
+		Column:    "column",
+		EndLine:   "endLine",
+		EndColumn: "endColumn",
+		Severity:  "severity",
+		Code:      "ruleId",
+		Message:   "message",
+	}
+	entries, err := parseJSONPath(paths, []byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries should be two but got: %v", entries)
+	}
+	if entries[0].Filename != "/src/index.js" || entries[1].Filename != "/src/index.js" {
+		t.Fatalf("filename should come from the enclosing object but got: %+v", entries)
+	}
+	if entries[0].Category != "2" || entries[0].Code != "no-unused-vars" || entries[0].EndCol != 8 {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+	if entries[1].Lnum != 3 || entries[1].Col != 10 || entries[1].Text != "Missing semicolon." {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestLintInvalidOutputFormat(t *testing.T) {
+	uri := DocumentURI("file:///foo")
+	h := &langHandler{
+		logger: log.New(log.Writer(), "", log.LstdFlags),
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:      `echo`,
+					LintOutputFormat: "unknown",
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+			},
+		},
+	}
+
+	if _, err := h.lint(context.Background(), uri, eventTypeChange); err == nil {
+		t.Fatal("an unknown lint-output-format should be an error")
+	}
+}
+
+func TestParseRDJSONSuggestions(t *testing.T) {
+	output := `{"diagnostics":[{"message":"use :=","location":{"path":"a.go","range":{"start":{"line":4,"column":2}}},"suggestions":[{"range":{"start":{"line":4,"column":2},"end":{"line":4,"column":11}},"text":"x := 1"}]}]}`
+	entries, err := parseRDJSON([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Fixes) != 1 {
+		t.Fatalf("entry should have one fix but got: %+v", entries)
+	}
+	edits := lintFixEdits("package a\n\nfunc f() {\n\tvar x = 1\n", entries[0].Fixes[0], 0, 0, PositionEncodingUTF16, PositionEncodingUTF16)
+	want := Range{Start: Position{Line: 3, Character: 1}, End: Position{Line: 3, Character: 10}}
+	if len(edits) != 1 || edits[0].Range != want || edits[0].NewText != "x := 1" {
+		t.Fatalf("edit should replace %v but got: %+v", want, edits)
+	}
+}
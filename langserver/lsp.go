@@ -1,5 +1,7 @@
 package langserver
 
+import "github.com/sourcegraph/jsonrpc2"
+
 const wildcard = "="
 
 // DocumentURI is
@@ -25,7 +27,64 @@ type InitializeOptions struct {
 }
 
 // ClientCapabilities is
-type ClientCapabilities struct{}
+type ClientCapabilities struct {
+	General      *GeneralClientCapabilities      `json:"general,omitempty"`
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+}
+
+// WorkspaceClientCapabilities is
+type WorkspaceClientCapabilities struct {
+	DidChangeWatchedFiles *DidChangeWatchedFilesClientCapabilities `json:"didChangeWatchedFiles,omitempty"`
+	Diagnostics           *DiagnosticWorkspaceClientCapabilities   `json:"diagnostics,omitempty"`
+}
+
+// DiagnosticWorkspaceClientCapabilities is
+type DiagnosticWorkspaceClientCapabilities struct {
+	RefreshSupport bool `json:"refreshSupport,omitempty"`
+}
+
+// DidChangeWatchedFilesClientCapabilities is
+type DidChangeWatchedFilesClientCapabilities struct {
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+}
+
+// GeneralClientCapabilities is
+type GeneralClientCapabilities struct {
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
+}
+
+// PositionEncodingKind is
+type PositionEncodingKind string
+
+// PositionEncodingUTF8 is
+const (
+	PositionEncodingUTF8  PositionEncodingKind = "utf-8"
+	PositionEncodingUTF16 PositionEncodingKind = "utf-16"
+	PositionEncodingUTF32 PositionEncodingKind = "utf-32"
+)
+
+// TextDocumentClientCapabilities is
+type TextDocumentClientCapabilities struct {
+	Diagnostic *DiagnosticClientCapabilities `json:"diagnostic,omitempty"`
+	CodeAction *CodeActionClientCapabilities `json:"codeAction,omitempty"`
+}
+
+// CodeActionClientCapabilities is
+type CodeActionClientCapabilities struct {
+	ResolveSupport *CodeActionResolveSupport `json:"resolveSupport,omitempty"`
+}
+
+// CodeActionResolveSupport is
+type CodeActionResolveSupport struct {
+	Properties []string `json:"properties"`
+}
+
+// DiagnosticClientCapabilities is
+type DiagnosticClientCapabilities struct {
+	DynamicRegistration    bool `json:"dynamicRegistration,omitempty"`
+	RelatedDocumentSupport bool `json:"relatedDocumentSupport,omitempty"`
+}
 
 // InitializeResult is
 type InitializeResult struct {
@@ -54,6 +113,20 @@ const (
 	TDSKIncremental
 )
 
+// TextDocumentSyncOptions is
+type TextDocumentSyncOptions struct {
+	OpenClose         bool                 `json:"openClose"`
+	Change            TextDocumentSyncKind `json:"change"`
+	WillSaveWaitUntil bool                 `json:"willSaveWaitUntil,omitempty"`
+	Save              bool                 `json:"save"`
+}
+
+// DocumentOnTypeFormattingOptions is
+type DocumentOnTypeFormattingOptions struct {
+	FirstTriggerCharacter string   `json:"firstTriggerCharacter"`
+	MoreTriggerCharacter  []string `json:"moreTriggerCharacter,omitempty"`
+}
+
 // CompletionProvider is
 type CompletionProvider struct {
 	ResolveProvider   bool     `json:"resolveProvider,omitempty"`
@@ -73,15 +146,30 @@ type ServerCapabilitiesWorkspace struct {
 
 // ServerCapabilities is
 type ServerCapabilities struct {
-	TextDocumentSync           TextDocumentSyncKind         `json:"textDocumentSync,omitempty"`
-	DocumentSymbolProvider     bool                         `json:"documentSymbolProvider,omitempty"`
-	CompletionProvider         *CompletionProvider          `json:"completionProvider,omitempty"`
-	DefinitionProvider         bool                         `json:"definitionProvider,omitempty"`
-	DocumentFormattingProvider bool                         `json:"documentFormattingProvider,omitempty"`
-	RangeFormattingProvider    bool                         `json:"documentRangeFormattingProvider,omitempty"`
-	HoverProvider              bool                         `json:"hoverProvider,omitempty"`
-	CodeActionProvider         bool                         `json:"codeActionProvider,omitempty"`
-	Workspace                  *ServerCapabilitiesWorkspace `json:"workspace,omitempty"`
+	PositionEncoding           PositionEncodingKind             `json:"positionEncoding,omitempty"`
+	TextDocumentSync           any                              `json:"textDocumentSync,omitempty"`
+	DocumentSymbolProvider     bool                             `json:"documentSymbolProvider,omitempty"`
+	CompletionProvider         *CompletionProvider              `json:"completionProvider,omitempty"`
+	DefinitionProvider         bool                             `json:"definitionProvider,omitempty"`
+	DocumentFormattingProvider bool                             `json:"documentFormattingProvider,omitempty"`
+	RangeFormattingProvider    bool                             `json:"documentRangeFormattingProvider,omitempty"`
+	OnTypeFormattingProvider   *DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
+	HoverProvider              bool                             `json:"hoverProvider,omitempty"`
+	CodeActionProvider         any                              `json:"codeActionProvider,omitempty"`
+	DiagnosticProvider         *DiagnosticOptions               `json:"diagnosticProvider,omitempty"`
+	Workspace                  *ServerCapabilitiesWorkspace     `json:"workspace,omitempty"`
+}
+
+// CodeActionOptions is
+type CodeActionOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+// DiagnosticOptions is
+type DiagnosticOptions struct {
+	Identifier            string `json:"identifier,omitempty"`
+	InterFileDependencies bool   `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool   `json:"workspaceDiagnostics"`
 }
 
 // TextDocumentItem is
@@ -115,8 +203,8 @@ type DidCloseTextDocumentParams struct {
 
 // TextDocumentContentChangeEvent is
 type TextDocumentContentChangeEvent struct {
-	Range       Range  `json:"range"`
-	RangeLength int    `json:"rangeLength"`
+	Range       *Range `json:"range,omitempty"`
+	RangeLength int    `json:"rangeLength,omitempty"`
 	Text        string `json:"text"`
 }
 
@@ -189,6 +277,70 @@ type Diagnostic struct {
 	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
 }
 
+// DocumentDiagnosticReportKind is
+type DocumentDiagnosticReportKind string
+
+// FullReport is
+const (
+	FullReport      DocumentDiagnosticReportKind = "full"
+	UnchangedReport DocumentDiagnosticReportKind = "unchanged"
+)
+
+// DocumentDiagnosticParams is
+type DocumentDiagnosticParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	Identifier       string                 `json:"identifier,omitempty"`
+	PreviousResultID string                 `json:"previousResultId,omitempty"`
+}
+
+// DocumentDiagnosticReport is
+type DocumentDiagnosticReport struct {
+	Kind             DocumentDiagnosticReportKind             `json:"kind"`
+	ResultID         string                                   `json:"resultId,omitempty"`
+	Items            []Diagnostic                             `json:"items"`
+	RelatedDocuments map[DocumentURI]DocumentDiagnosticReport `json:"relatedDocuments,omitempty"`
+}
+
+// PreviousResultID is
+type PreviousResultID struct {
+	URI   DocumentURI `json:"uri"`
+	Value string      `json:"value"`
+}
+
+// WorkspaceDiagnosticParams is
+type WorkspaceDiagnosticParams struct {
+	WorkDoneProgressParams
+	PartialResultParams
+
+	Identifier        string             `json:"identifier,omitempty"`
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+}
+
+// WorkspaceDocumentDiagnosticReport is
+type WorkspaceDocumentDiagnosticReport struct {
+	DocumentDiagnosticReport
+	URI     DocumentURI `json:"uri"`
+	Version *int        `json:"version"`
+}
+
+// WorkspaceDiagnosticReport is
+type WorkspaceDiagnosticReport struct {
+	Items []WorkspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// DiagnosticServerCancellationData is
+type DiagnosticServerCancellationData struct {
+	RetriggerRequest bool `json:"retriggerRequest"`
+}
+
+// CancelParams is
+type CancelParams struct {
+	ID jsonrpc2.ID `json:"id"`
+}
+
 // PublishDiagnosticsParams is
 type PublishDiagnosticsParams struct {
 	URI         DocumentURI  `json:"uri"`
@@ -205,6 +357,29 @@ type DocumentFormattingParams struct {
 	Options      FormattingOptions      `json:"options"`
 }
 
+// TextDocumentSaveReason is
+type TextDocumentSaveReason int
+
+// Manual is
+const (
+	Manual TextDocumentSaveReason = iota + 1
+	AfterDelay
+	FocusOut
+)
+
+// WillSaveTextDocumentParams is
+type WillSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Reason       TextDocumentSaveReason `json:"reason"`
+}
+
+// DocumentOnTypeFormattingParams is
+type DocumentOnTypeFormattingParams struct {
+	TextDocumentPositionParams
+	Ch      string            `json:"ch"`
+	Options FormattingOptions `json:"options"`
+}
+
 // DocumentRangeFormattingParams is
 type DocumentRangeFormattingParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -282,21 +457,27 @@ type Command struct {
 	Command   string `json:"command" yaml:"command"`
 	Arguments []any  `json:"arguments,omitempty" yaml:"arguments,omitempty"`
 	OS        string `json:"-" yaml:"os,omitempty"`
+
+	// Argv is set when command is configured as an argv list, which is run
+	// without a shell followed by the arguments.
+	Argv []string `json:"-" yaml:"-"`
 }
 
 // WorkspaceEdit is
 type WorkspaceEdit struct {
-	Changes         any `json:"changes"`         // { [uri: DocumentUri]: TextEdit[]; };
-	DocumentChanges any `json:"documentChanges"` // (TextDocumentEdit[] | (TextDocumentEdit | CreateFile | RenameFile | DeleteFile)[]);
+	Changes         any `json:"changes,omitempty"`         // { [uri: DocumentUri]: TextEdit[]; };
+	DocumentChanges any `json:"documentChanges,omitempty"` // (TextDocumentEdit[] | (TextDocumentEdit | CreateFile | RenameFile | DeleteFile)[]);
 }
 
 // CodeAction is
 type CodeAction struct {
 	Title       string         `json:"title"`
-	Diagnostics []Diagnostic   `json:"diagnostics"`
-	IsPreferred bool           `json:"isPreferred"` // TODO
-	Edit        *WorkspaceEdit `json:"edit"`
-	Command     *Command       `json:"command"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+	Command     *Command       `json:"command,omitempty"`
+	Data        any            `json:"data,omitempty"`
 }
 
 // CompletionItem is
@@ -372,6 +553,7 @@ const (
 	RefactorRewrite       CodeActionKind = "refactor.rewrite"
 	Source                CodeActionKind = "source"
 	SourceOrganizeImports CodeActionKind = "source.organizeImports"
+	SourceFixAll          CodeActionKind = "source.fixAll"
 )
 
 // CodeActionContext is
@@ -419,6 +601,18 @@ type ShowMessageParams struct {
 	Message string      `json:"message"`
 }
 
+// ShowMessageRequestParams is
+type ShowMessageRequestParams struct {
+	Type    MessageType         `json:"type"`
+	Message string              `json:"message"`
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+// MessageActionItem is
+type MessageActionItem struct {
+	Title string `json:"title"`
+}
+
 // LogMessageParams is
 type LogMessageParams struct {
 	Type    MessageType `json:"type"`
@@ -441,3 +635,58 @@ type WorkspaceFolder struct {
 	URI  DocumentURI `json:"uri"`
 	Name string      `json:"name"`
 }
+
+// Registration is
+type Registration struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	RegisterOptions any    `json:"registerOptions,omitempty"`
+}
+
+// RegistrationParams is
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// Unregistration is
+type Unregistration struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
+
+// UnregistrationParams is
+type UnregistrationParams struct {
+	// The misspelling is in the protocol.
+	Unregisterations []Unregistration `json:"unregisterations"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions is
+type DidChangeWatchedFilesRegistrationOptions struct {
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileSystemWatcher is
+type FileSystemWatcher struct {
+	GlobPattern string `json:"globPattern"`
+}
+
+// FileChangeType is
+type FileChangeType int
+
+// FileCreated is
+const (
+	FileCreated FileChangeType = iota + 1
+	FileChanged
+	FileDeleted
+)
+
+// FileEvent is
+type FileEvent struct {
+	URI  DocumentURI    `json:"uri"`
+	Type FileChangeType `json:"type"`
+}
+
+// DidChangeWatchedFilesParams is
+type DidChangeWatchedFilesParams struct {
+	Changes []FileEvent `json:"changes"`
+}
@@ -0,0 +1,146 @@
+package langserver
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hasMatchers reports whether the tool cfg is matched against the name and
+// the shebang of documents instead of their language.
+func hasMatchers(cfg Language) bool {
+	return len(cfg.FilenamePatterns) > 0 || len(cfg.Shebang) > 0
+}
+
+// documentSkipReason returns why the tool cfg does not apply to the
+// document fname starting with firstLine, or "" when it does.
+func documentSkipReason(cfg Language, fname, firstLine string) string {
+	for _, pattern := range cfg.ExcludePatterns {
+		if matchGlob(pattern, fname) {
+			return "exclude-patterns match " + pattern
+		}
+	}
+	if !hasMatchers(cfg) {
+		return ""
+	}
+	for _, pattern := range cfg.FilenamePatterns {
+		if matchGlob(pattern, fname) {
+			return ""
+		}
+	}
+	if interpreter := shebangInterpreter(firstLine); interpreter != "" {
+		for _, name := range cfg.Shebang {
+			if name == interpreter {
+				return ""
+			}
+		}
+	}
+	return "neither filename-patterns nor shebang match"
+}
+
+// shebangInterpreter returns the name of the interpreter of a "#!" line,
+// looking through env, so that both "#!/bin/bash" and "#!/usr/bin/env bash"
+// give "bash".
+func shebangInterpreter(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return ""
+	}
+	if filepath.Base(fields[0]) == "env" {
+		for _, field := range fields[1:] {
+			// Options and variables set by env.
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			return filepath.Base(field)
+		}
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// firstLine returns the first line of the document uri, read from the file
+// when it is not open. The file is read without h.mu held.
+func (h *langHandler) firstLine(uri DocumentURI) string {
+	h.mu.Lock()
+	f, ok := h.files[uri]
+	var text string
+	if ok {
+		text = f.Text
+	}
+	h.mu.Unlock()
+	if ok {
+		return textFirstLine(text)
+	}
+	fname, err := fromURI(uri)
+	if err != nil {
+		return ""
+	}
+	file, err := os.Open(fname)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+// firstLineLocked returns the first line of the document uri when it is
+// open, and "" otherwise. h.mu must be held.
+func (h *langHandler) firstLineLocked(uri DocumentURI) string {
+	if f, ok := h.files[uri]; ok {
+		return textFirstLine(f.Text)
+	}
+	return ""
+}
+
+// textFirstLine returns the first line of text.
+func textFirstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// matchedTool is a tool configured for the language of its key, which
+// applies to a document of another language it matches.
+type matchedTool struct {
+	language string
+	config   Language
+}
+
+// matchedToolsLocked returns the tools with filename-patterns or shebang of
+// the languages other than languageID and wildcard which match the
+// document fname, by language. h.mu must be held.
+func (h *langHandler) matchedToolsLocked(uri DocumentURI, languageID, fname, firstLine string) []matchedTool {
+	seen := map[string]bool{languageID: true, wildcard: true}
+	var languages []string
+	for lang := range h.configs {
+		if !seen[lang] {
+			seen[lang] = true
+			languages = append(languages, lang)
+		}
+	}
+	if p := h.projectFor(filepath.FromSlash(fname)); p != nil {
+		for lang := range p.languages {
+			if !seen[lang] {
+				seen[lang] = true
+				languages = append(languages, lang)
+			}
+		}
+	}
+	sort.Strings(languages)
+
+	var matched []matchedTool
+	for _, lang := range languages {
+		for _, cfg := range h.languageToolsLocked(uri, lang) {
+			if hasMatchers(cfg) && documentSkipReason(cfg, fname, firstLine) == "" {
+				matched = append(matched, matchedTool{language: lang, config: cfg})
+			}
+		}
+	}
+	return matched
+}
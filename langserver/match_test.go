@@ -0,0 +1,109 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShebangInterpreter(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"#!/bin/bash", "bash"},
+		{"#! /bin/sh -e", "sh"},
+		{"#!/usr/bin/env bash", "bash"},
+		{"#!/usr/bin/env -S PYTHONPATH=. python3 -u", "python3"},
+		{"#!/usr/bin/env", ""},
+		{"# comment", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := shebangInterpreter(test.line); got != test.want {
+			t.Errorf("shebangInterpreter(%q) should be %q but got: %q", test.line, test.want, got)
+		}
+	}
+}
+
+func TestLanguageConfigsMatchers(t *testing.T) {
+	base := t.TempDir()
+	h := &langHandler{
+		logger: log.New(io.Discard, "", 0),
+		files:  map[DocumentURI]*File{},
+		configs: map[string][]Language{
+			"dockerfile": {
+				{Name: "hadolint", LintCommand: "hadolint", FilenamePatterns: []string{"Dockerfile", "Dockerfile.*"}},
+			},
+			"sh": {
+				{Name: "shellcheck", LintCommand: "shellcheck", Shebang: []string{"sh", "bash"}, ExcludePatterns: []string{"vendor/**"}},
+				{Name: "shfmt", FormatCommand: "shfmt"},
+			},
+			wildcard: {
+				{Name: "codespell", LintCommand: "codespell", ExcludePatterns: []string{"*.min.js"}},
+			},
+		},
+	}
+	open := func(name, languageID, text string) DocumentURI {
+		uri := toURI(filepath.Join(base, name))
+		h.files[uri] = &File{LanguageID: languageID, Text: text}
+		return uri
+	}
+	names := func(configs []Language) []string {
+		var names []string
+		for _, cfg := range configs {
+			names = append(names, cfg.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		uri        DocumentURI
+		languageID string
+		want       []string
+	}{
+		{open("Dockerfile.prod", "text", ""), "text", []string{"hadolint"}},
+		{open("Dockerfile", "dockerfile", ""), "dockerfile", []string{"hadolint"}},
+		{open("build.dockerfile", "dockerfile", ""), "dockerfile", nil},
+		{open("bin/deploy", "", "#!/usr/bin/env bash\necho\n"), "", []string{"shellcheck"}},
+		{open("a.sh", "sh", "#!/bin/bash\n"), "sh", []string{"shellcheck", "shfmt"}},
+		{open("b.sh", "sh", "echo\n"), "sh", []string{"shfmt"}},
+		{open("vendor/c.sh", "sh", "#!/bin/sh\n"), "sh", []string{"shfmt"}},
+		{open("d.min.js", "javascript", ""), wildcard, nil},
+		{open("d.js", "javascript", ""), wildcard, []string{"codespell"}},
+	}
+	for _, test := range tests {
+		got := names(h.languageConfigs(test.uri, test.languageID))
+		if len(got) != len(test.want) {
+			t.Errorf("tools of %v (%s) should be %v but got: %v", test.uri, test.languageID, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("tools of %v (%s) should be %v but got: %v", test.uri, test.languageID, test.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestLanguageConfigsClosedDocument(t *testing.T) {
+	base := t.TempDir()
+	fname := filepath.Join(base, "run")
+	if err := os.WriteFile(fname, []byte("#!/bin/sh\necho\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h := &langHandler{
+		logger: log.New(io.Discard, "", 0),
+		files:  map[DocumentURI]*File{},
+		configs: map[string][]Language{
+			"sh": {{Name: "shellcheck", LintCommand: "shellcheck", Shebang: []string{"sh"}}},
+		},
+	}
+	// The shebang of documents which are not open is read from the file.
+	if got := h.languageConfigs(toURI(fname), ""); len(got) != 1 || got[0].Name != "shellcheck" {
+		t.Fatalf("the shebang of the file should match but got: %v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package langserver
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported values for lint-column-unit.
+const (
+	columnUnitByte  = "byte"
+	columnUnitRune  = "rune"
+	columnUnitUTF16 = "utf16"
+)
+
+// negotiatePositionEncoding picks the first of the encodings offered by the
+// client that the server supports. Clients not offering any only support
+// UTF-16.
+func negotiatePositionEncoding(offered []PositionEncodingKind) PositionEncodingKind {
+	for _, enc := range offered {
+		switch enc {
+		case PositionEncodingUTF8, PositionEncodingUTF16, PositionEncodingUTF32:
+			return enc
+		}
+	}
+	return PositionEncodingUTF16
+}
+
+// columnUnitEncoding returns the encoding whose code units are counted by a
+// linter reporting columns in unit.
+func columnUnitEncoding(unit string) (PositionEncodingKind, error) {
+	switch unit {
+	case "", columnUnitUTF16:
+		return PositionEncodingUTF16, nil
+	case columnUnitByte:
+		return PositionEncodingUTF8, nil
+	case columnUnitRune:
+		return PositionEncodingUTF32, nil
+	}
+	return "", fmt.Errorf("invalid lint-column-unit: %v", unit)
+}
+
+// encodedLen returns the length of s in code units of enc.
+func encodedLen(s string, enc PositionEncodingKind) int {
+	switch enc {
+	case PositionEncodingUTF8:
+		return len(s)
+	case PositionEncodingUTF32:
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
+
+// characterOffset returns the byte offset in line of char code units of
+// enc, clamped to the length of line.
+func characterOffset(line string, char int, enc PositionEncodingKind) int {
+	if enc == PositionEncodingUTF8 {
+		if char > len(line) {
+			return len(line)
+		}
+		return char
+	}
+	offset := 0
+	for n := 0; n < char && offset < len(line); {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+		n++
+		if enc != PositionEncodingUTF32 && r >= 0x10000 {
+			// Outside the BMP a rune takes a surrogate pair.
+			n++
+		}
+	}
+	return offset
+}
+
+// convertCharacter converts a character offset in line from one encoding to
+// another. Offsets past the end of line are kept past the end, so callers
+// can still tell them apart.
+func convertCharacter(line string, char int, from, to PositionEncodingKind) int {
+	if from == to || char <= 0 {
+		return char
+	}
+	offset := characterOffset(line, char, from)
+	converted := encodedLen(line[:offset], to)
+	if rest := char - encodedLen(line, from); rest > 0 {
+		converted += rest
+	}
+	return converted
+}
+
+// lineAt returns line n of text without its line break, or "" if there is no
+// such line.
+func lineAt(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n], "\r")
+}
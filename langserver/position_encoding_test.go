@@ -0,0 +1,226 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	tests := []struct {
+		offered []PositionEncodingKind
+		want    PositionEncodingKind
+	}{
+		{nil, PositionEncodingUTF16},
+		{[]PositionEncodingKind{"utf-7", PositionEncodingUTF8, PositionEncodingUTF16}, PositionEncodingUTF8},
+		{[]PositionEncodingKind{PositionEncodingUTF32}, PositionEncodingUTF32},
+		{[]PositionEncodingKind{"utf-7"}, PositionEncodingUTF16},
+	}
+	for _, tt := range tests {
+		if got := negotiatePositionEncoding(tt.offered); got != tt.want {
+			t.Errorf("negotiatePositionEncoding(%v) should be %v but got: %v", tt.offered, tt.want, got)
+		}
+	}
+}
+
+func TestConvertCharacter(t *testing.T) {
+	// "あ" is 3 bytes and 1 UTF-16 unit, "😀" is 4 bytes and 2 UTF-16 units.
+	line := "あ😀x"
+	tests := []struct {
+		char     int
+		from, to PositionEncodingKind
+		want     int
+	}{
+		{3, PositionEncodingUTF8, PositionEncodingUTF16, 1},
+		{7, PositionEncodingUTF8, PositionEncodingUTF16, 3},
+		{7, PositionEncodingUTF8, PositionEncodingUTF32, 2},
+		{3, PositionEncodingUTF16, PositionEncodingUTF8, 7},
+		{2, PositionEncodingUTF32, PositionEncodingUTF16, 3},
+		// Past the end of the line stays past the end.
+		{10, PositionEncodingUTF8, PositionEncodingUTF16, 6},
+	}
+	for _, tt := range tests {
+		if got := convertCharacter(line, tt.char, tt.from, tt.to); got != tt.want {
+			t.Errorf("convertCharacter(%q, %d, %v, %v) should be %d but got: %d", line, tt.char, tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestConvertRowColToIndex(t *testing.T) {
+	text := "😀\nあいx\n"
+	tests := []struct {
+		col       int
+		enc, unit PositionEncodingKind
+		want      int
+	}{
+		{2, PositionEncodingUTF16, PositionEncodingUTF8, 11},
+		{6, PositionEncodingUTF8, PositionEncodingUTF8, 11},
+		{2, PositionEncodingUTF16, PositionEncodingUTF16, 5},
+		{6, PositionEncodingUTF8, PositionEncodingUTF16, 5},
+	}
+	for _, tt := range tests {
+		if got := convertRowColToIndex(text, 1, tt.col, tt.enc, tt.unit); got != tt.want {
+			t.Errorf("convertRowColToIndex(%q, 1, %d, %v, %v) should be %d but got: %d", text, tt.col, tt.enc, tt.unit, tt.want, got)
+		}
+	}
+}
+
+func TestLintColumnUnit(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	tests := []struct {
+		unit     string
+		encoding PositionEncodingKind
+		col      string
+		start    int
+		end      int
+	}{
+		// The byte column 7 points at "x" after two 3 byte characters.
+		{columnUnitByte, "", "7", 2, 3},
+		{columnUnitByte, PositionEncodingUTF8, "7", 6, 7},
+		{columnUnitRune, PositionEncodingUTF16, "4", 5, 6},
+		{"", PositionEncodingUTF16, "3", 2, 3},
+	}
+	for _, tt := range tests {
+		h := &langHandler{
+			logger:           log.New(log.Writer(), "", log.LstdFlags),
+			rootPath:         base,
+			positionEncoding: tt.encoding,
+			configs: map[string][]Language{
+				wildcard: {
+					{
+						LintCommand:        `echo ` + file + `:1:` + tt.col + `:msg`,
+						LintFormats:        []string{"%f:%l:%c:%m"},
+						LintIgnoreExitCode: true,
+						LintStdin:          true,
+						LintColumnUnit:     tt.unit,
+					},
+				},
+			},
+			files: map[DocumentURI]*File{
+				uri: {
+					LanguageID: "vim",
+				},
+			},
+		}
+		h.files[uri].Text = "あいx y\n"
+		if tt.unit == columnUnitRune {
+			h.files[uri].Text = "😀😀 x\n"
+		}
+
+		uriToDiag, err := h.lint(context.Background(), uri, eventTypeChange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := uriToDiag[uri]
+		if len(d) != 1 {
+			t.Fatal("diagnostics should be only one")
+		}
+		if d[0].Range.Start.Character != tt.start || d[0].Range.End.Character != tt.end {
+			t.Fatalf("%v columns for a %v client should span %d-%d but got: %v", tt.unit, tt.encoding, tt.start, tt.end, d[0].Range)
+		}
+	}
+}
+
+func TestLintColumnUnitWorkspace(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	// The other file is not open, so its text is read from disk.
+	other := filepath.Join(t.TempDir(), "bar")
+	if err := os.WriteFile(other, []byte("あいx y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			wildcard: {
+				{
+					LintCommand:        `echo ` + other + `:1:7:msg`,
+					LintFormats:        []string{"%f:%l:%c:%m"},
+					LintIgnoreExitCode: true,
+					LintWorkspace:      true,
+					LintColumnUnit:     columnUnitByte,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "foo\n",
+			},
+		},
+		lastPublishedURIs: make(map[string]map[DocumentURI]struct{}),
+	}
+
+	uriToDiag, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := uriToDiag[toURI(other)]
+	if len(d) != 1 {
+		t.Fatalf("diagnostics of the other file should be only one but got: %v", uriToDiag)
+	}
+	if d[0].Range.Start.Character != 2 || d[0].Range.End.Character != 3 {
+		t.Fatalf("byte columns of the other file should span 2-3 but got: %v", d[0].Range)
+	}
+}
+
+func TestCompletionPositionColumnUnit(t *testing.T) {
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+
+	tests := []struct {
+		unit     string
+		encoding PositionEncodingKind
+		char     int
+		want     string
+	}{
+		// The UTF-16 character 3 is just after "x" behind two 3 byte characters.
+		{columnUnitByte, PositionEncodingUTF16, 3, "1:7"},
+		{columnUnitRune, PositionEncodingUTF8, 7, "1:3"},
+		{"", PositionEncodingUTF16, 3, "1:3"},
+	}
+	for _, tt := range tests {
+		h := &langHandler{
+			logger:           log.New(log.Writer(), "", log.LstdFlags),
+			rootPath:         base,
+			positionEncoding: tt.encoding,
+			configs: map[string][]Language{
+				wildcard: {
+					{
+						CompletionCommand: `echo ${POSITION}`,
+						CompletionStdin:   true,
+						LintColumnUnit:    tt.unit,
+					},
+				},
+			},
+			files: map[DocumentURI]*File{
+				uri: {
+					LanguageID: "vim",
+					Text:       "foo\nあいx\n",
+				},
+			},
+		}
+
+		items, err := h.completion(uri, &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				Position:     Position{Line: 1, Character: tt.char},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].Label != tt.want {
+			t.Fatalf("${POSITION} in %v for a %v client should be %q but got: %v", tt.unit, tt.encoding, tt.want, items)
+		}
+	}
+}
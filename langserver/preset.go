@@ -0,0 +1,49 @@
+package langserver
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// presets are the tool definitions shipped with the server, which tools can
+// be based on with `preset`, one file by preset.
+//
+//go:embed presets/*.yaml
+var presets embed.FS
+
+// PresetNames returns the names of the presets, sorted.
+func PresetNames() []string {
+	entries, _ := fs.ReadDir(presets, "presets")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	return names
+}
+
+// PresetConfig returns the configuration of the preset name.
+func PresetConfig(name string) ([]byte, error) {
+	b, err := presets.ReadFile(path.Join("presets", path.Base(name)+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+	return b, nil
+}
+
+// loadPreset decodes the preset name.
+func loadPreset(name string) (Language, error) {
+	b, err := PresetConfig(name)
+	if err != nil {
+		return Language{}, err
+	}
+	var preset Language
+	if err := yaml.Unmarshal(b, &preset); err != nil {
+		return Language{}, fmt.Errorf("can not read preset %q: %v", name, err)
+	}
+	return preset, nil
+}
@@ -0,0 +1,185 @@
+package langserver
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPresets(t *testing.T) {
+	type diagnostic struct {
+		line     int
+		col      int
+		severity string
+		message  string
+	}
+	// Outputs captured from the linters.
+	tests := map[string]struct {
+		output string
+		want   []diagnostic
+	}{
+		"eslint": {
+			output: `[{"filePath":"/src/index.js","messages":[` +
+				`{"ruleId":"no-unused-vars","severity":2,"message":"'a' is assigned a value but never used.","line":1,"column":7,"endLine":1,"endColumn":8},` +
+				`{"ruleId":"semi","severity":1,"message":"Missing semicolon.","line":2,"column":12}` +
+				`],"errorCount":1,"warningCount":1}]`,
+			want: []diagnostic{
+				{1, 7, "E", "'a' is assigned a value but never used."},
+				{2, 12, "W", "Missing semicolon."},
+			},
+		},
+		"flake8": {
+			output: "/src/a.py:1:1: F401 'os' imported but unused\n" +
+				"/src/a.py:3:1: E302 expected 2 blank lines, found 1\n" +
+				"/src/a.py:4:80: W291 trailing whitespace\n" +
+				"/src/a.py:5:1: C901 'f' is too complex (11)\n",
+			want: []diagnostic{
+				{1, 1, "E", "'os' imported but unused"},
+				{3, 1, "E", "expected 2 blank lines, found 1"},
+				{4, 80, "W", "trailing whitespace"},
+				{5, 1, "W", "'f' is too complex (11)"},
+			},
+		},
+		"hadolint": {
+			output: "-:1 DL3006 warning: Always tag the version of an image explicitly\n" +
+				"-:3 DL3003 warning: Use WORKDIR to switch to a directory\n" +
+				"-:4 SC2086 info: Double quote to prevent globbing and word splitting.\n" +
+				"-:5 DL3059 info: Multiple consecutive `RUN` instructions. Consider consolidation.\n" +
+				"-:6 DL4000 error: MAINTAINER is deprecated\n" +
+				"-:7 SC2006 style: Use $(...) notation instead of legacy backticks `...`.\n",
+			want: []diagnostic{
+				{1, 0, "w", "Always tag the version of an image explicitly"},
+				{3, 0, "w", "Use WORKDIR to switch to a directory"},
+				{4, 0, "i", "Double quote to prevent globbing and word splitting."},
+				{5, 0, "i", "Multiple consecutive `RUN` instructions. Consider consolidation."},
+				{6, 0, "e", "MAINTAINER is deprecated"},
+				{7, 0, "N", "Use $(...) notation instead of legacy backticks `...`."},
+			},
+		},
+		"luacheck": {
+			output: "/src/a.lua:1:7: (W211) unused variable 'x'\n" +
+				"/src/a.lua:3:1: (E011) expected '=' near 'end'\n",
+			want: []diagnostic{
+				{1, 7, "W", "unused variable 'x'"},
+				{3, 1, "E", "expected '=' near 'end'"},
+			},
+		},
+		"markdownlint": {
+			output: "stdin:1 MD041/first-line-heading/first-line-h1 First line in a file should be a top-level heading [Context: \"text\"]\n" +
+				"stdin:3:10 MD009/no-trailing-spaces Trailing spaces [Expected: 0 or 2; Actual: 1]\n" +
+				"stdin:5 error MD022/blanks-around-headings Headings should be surrounded by blank lines\n" +
+				"stdin:7:1 warning MD032/blanks-around-lists Lists should be surrounded by blank lines\n",
+			want: []diagnostic{
+				{1, 0, "", "MD041/first-line-heading/first-line-h1 First line in a file should be a top-level heading [Context: \"text\"]"},
+				{3, 10, "", "MD009/no-trailing-spaces Trailing spaces [Expected: 0 or 2; Actual: 1]"},
+				{5, 0, "e", "MD022/blanks-around-headings Headings should be surrounded by blank lines"},
+				{7, 1, "w", "MD032/blanks-around-lists Lists should be surrounded by blank lines"},
+			},
+		},
+		"mypy": {
+			output: "a.py:3:5: error: Incompatible types in assignment (expression has type \"str\", variable has type \"int\")  [assignment]\n" +
+				"a.py:7:1: note: Revealed type is \"builtins.int\"\n",
+			want: []diagnostic{
+				{3, 5, "e", "Incompatible types in assignment (expression has type \"str\", variable has type \"int\")  [assignment]"},
+				{7, 1, "n", "Revealed type is \"builtins.int\""},
+			},
+		},
+		"shellcheck": {
+			output: "-:3:6: warning: foo is referenced but not assigned. [SC2154]\n" +
+				"-:4:6: note: Double quote to prevent globbing and word splitting. [SC2086]\n" +
+				"-:6:1: error: Couldn't parse this function. Fix to allow more checks. [SC1073]\n",
+			want: []diagnostic{
+				{3, 6, "w", "foo is referenced but not assigned. [SC2154]"},
+				{4, 6, "n", "Double quote to prevent globbing and word splitting. [SC2086]"},
+				{6, 1, "e", "Couldn't parse this function. Fix to allow more checks. [SC1073]"},
+			},
+		},
+		"vint": {
+			output: "stdin:1:1: Use scriptencoding when multibyte char exists (see :help :scriptencoding)\n",
+			want: []diagnostic{
+				{1, 1, "", "Use scriptencoding when multibyte char exists (see :help :scriptencoding)"},
+			},
+		},
+		"yamllint": {
+			output: "stdin:1:1: [warning] missing document start \"---\" (document-start)\n" +
+				"stdin:3:3: [error] wrong indentation: expected 2 but found 4 (indentation)\n",
+			want: []diagnostic{
+				{1, 1, "w", "missing document start \"---\" (document-start)"},
+				{3, 3, "e", "wrong indentation: expected 2 but found 4 (indentation)"},
+			},
+		},
+	}
+
+	for _, name := range PresetNames() {
+		t.Run(name, func(t *testing.T) {
+			l, err := loadPreset(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.LintCommand == "" && l.FormatCommand == "" {
+				t.Fatal("the preset should configure a command")
+			}
+			if l.LintCommand == "" {
+				return
+			}
+			// Linters exit with an error when they report anything.
+			if !l.LintIgnoreExitCode {
+				t.Fatal("the preset should set lint-ignore-exit-code")
+			}
+			test, ok := tests[name]
+			if !ok {
+				t.Fatal("the preset should be tested against an output of the linter")
+			}
+			parse, err := newLintParser(&l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := parse([]byte(test.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []diagnostic
+			for _, entry := range entries {
+				var severity string
+				if entry.Type != 0 {
+					severity = string(entry.Type)
+				}
+				if mapped, ok := l.LintCategoryMap[entry.Category]; ok {
+					severity = mapped
+				}
+				got = append(got, diagnostic{entry.Lnum, entry.Col, severity, entry.Text})
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("%d diagnostics should be parsed but got: %v", len(test.want), got)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("diagnostic %d should be %v but got: %v", i, test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveToolsPreset(t *testing.T) {
+	var languages map[string][]Language
+	err := yaml.Unmarshal([]byte("sh:\n  - preset: shellcheck\n    lint-command: shellcheck -f gcc -S warning -\n"), &languages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resolveTools(languages, nil); err != nil {
+		t.Fatal(err)
+	}
+	sh := languages["sh"][0]
+	if sh.Name != "shellcheck" || sh.LintSource != "shellcheck" || !sh.LintStdin || len(sh.LintFormats) != 3 {
+		t.Fatalf("the tool should be based on the preset but got: %+v", sh)
+	}
+	if sh.LintCommand != "shellcheck -f gcc -S warning -" {
+		t.Fatalf("the settings of the tool should override the preset but got: %q", sh.LintCommand)
+	}
+
+	err = resolveTools(map[string][]Language{"sh": {{Preset: "none"}}}, nil)
+	if err == nil || err.Error() != `languages.sh[0]: unknown preset "none"` {
+		t.Fatalf("the unknown preset should be reported but got: %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package langserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigName is the name of project configuration files, which add
+// tools to the user configuration for the workspace folders they are in.
+const projectConfigName = ".efm-langserver.yaml"
+
+// projectConfig is a project configuration file. Its commands are only run
+// once the user trusts the file with its current contents.
+type projectConfig struct {
+	file      string
+	dir       string
+	hash      string
+	languages map[string][]Language
+	commands  []Command
+	trusted   bool
+	// asked is set once the user has been asked to trust the file.
+	asked bool
+}
+
+// loadProjectConfig reads the project configuration file.
+func loadProjectConfig(file string) (*projectConfig, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("can not read %s: %v", file, err)
+	}
+	setToolFile(&config, file)
+	if config.Languages != nil {
+		if err := resolveTools(*config.Languages, config.Tools); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(b)
+	p := &projectConfig{
+		file: file,
+		dir:  filepath.Dir(file),
+		hash: hex.EncodeToString(sum[:]),
+	}
+	if config.Languages != nil {
+		p.languages = *config.Languages
+	}
+	if config.Commands != nil {
+		p.commands = *config.Commands
+	}
+	return p, nil
+}
+
+// mergeLanguages returns the tools of project added to those of user. A
+// project tool replaces the user tool of the same name.
+func mergeLanguages(user, project []Language) []Language {
+	if len(project) == 0 {
+		return user
+	}
+	merged := append([]Language{}, user...)
+	for _, tool := range project {
+		replaced := false
+		if tool.Name != "" {
+			for i := range merged {
+				if merged[i].Name == tool.Name {
+					merged[i] = tool
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			merged = append(merged, tool)
+		}
+	}
+	return merged
+}
+
+// projectFor returns the trusted project configuration applying to fname,
+// or nil. h.mu must be held.
+func (h *langHandler) projectFor(fname string) *projectConfig {
+	var found *projectConfig
+	for _, p := range h.projects {
+		if !p.trusted || !isInDir(fname, p.dir) {
+			continue
+		}
+		if found == nil || len(p.dir) > len(found.dir) {
+			found = p
+		}
+	}
+	return found
+}
+
+func isInDir(fname, dir string) bool {
+	fname = filepath.Clean(fname)
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	if len(fname) <= len(dir) {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(fname[:len(dir)], dir)
+	}
+	return fname[:len(dir)] == dir
+}
+
+// languageConfigs returns the tools of languageID applying to the document
+// uri, including those of its project, followed by the tools of other
+// languages matching it by name or shebang.
+func (h *langHandler) languageConfigs(uri DocumentURI, languageID string) []Language {
+	firstLine := h.firstLine(uri)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.documentToolsLocked(uri, languageID, firstLine)
+}
+
+// languageConfigsLocked is languageConfigs with h.mu held, which only knows
+// the first line of open documents.
+func (h *langHandler) languageConfigsLocked(uri DocumentURI, languageID string) []Language {
+	return h.documentToolsLocked(uri, languageID, h.firstLineLocked(uri))
+}
+
+// documentToolsLocked is languageConfigs for a document starting with
+// firstLine. h.mu must be held.
+func (h *langHandler) documentToolsLocked(uri DocumentURI, languageID, firstLine string) []Language {
+	fname, err := fromURI(uri)
+	if err != nil {
+		return h.configs[languageID]
+	}
+	var configs []Language
+	for _, cfg := range h.languageToolsLocked(uri, languageID) {
+		if documentSkipReason(cfg, fname, firstLine) == "" {
+			configs = append(configs, cfg)
+		}
+	}
+	if languageID != wildcard {
+		for _, m := range h.matchedToolsLocked(uri, languageID, fname, firstLine) {
+			configs = append(configs, m.config)
+		}
+	}
+	return configs
+}
+
+// languageToolsLocked returns the tools configured for languageID, including
+// those of the project of uri. h.mu must be held.
+func (h *langHandler) languageToolsLocked(uri DocumentURI, languageID string) []Language {
+	configs := h.configs[languageID]
+	if fname, err := fromURI(uri); err == nil {
+		if p := h.projectFor(filepath.FromSlash(fname)); p != nil {
+			configs = mergeLanguages(configs, p.languages[languageID])
+		}
+	}
+	return configs
+}
+
+// documentConfigs returns the tools applying to the document uri of
+// languageID, followed by the wildcard tools. h.mu must be held.
+func (h *langHandler) documentConfigs(uri DocumentURI, languageID string) []Language {
+	configs := append([]Language{}, h.languageConfigsLocked(uri, languageID)...)
+	return append(configs, h.languageConfigsLocked(uri, wildcard)...)
+}
+
+// projectCommands returns the commands of the project of uri. h.mu must be
+// held.
+func (h *langHandler) projectCommands(uri DocumentURI) []Command {
+	if fname, err := fromURI(uri); err == nil {
+		if p := h.projectFor(filepath.FromSlash(fname)); p != nil {
+			return p.commands
+		}
+	}
+	return nil
+}
+
+// folderOf returns the workspace folder of fname, or "" when it is in none.
+func (h *langHandler) folderOf(fname string) string {
+	h.mu.Lock()
+	folders := append([]string{}, h.folders...)
+	rootPath := h.rootPath
+	h.mu.Unlock()
+
+	var found string
+	for _, folder := range append(folders, rootPath) {
+		if folder != "" && isInDir(fname, folder) && len(folder) > len(found) {
+			found = folder
+		}
+	}
+	return found
+}
+
+// discoverProject loads the project configuration of the workspace folder of
+// uri, found in the folder itself, and asks the user whether to trust it
+// unless it is trusted already. Directories above the folder are not
+// searched, as they are not part of the workspace.
+func (h *langHandler) discoverProject(uri DocumentURI) {
+	fname, err := fromURI(uri)
+	if err != nil {
+		return
+	}
+	folder := h.folderOf(filepath.FromSlash(fname))
+	if folder == "" {
+		return
+	}
+	file := filepath.Join(folder, projectConfigName)
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return
+	}
+
+	h.mu.Lock()
+	_, ok := h.projects[file]
+	h.mu.Unlock()
+	if !ok {
+		h.loadProject(file)
+	}
+}
+
+// loadProject loads the project configuration file, in place of the one
+// loaded before. Errors are shown to the user, and the configuration
+// loaded before is kept.
+func (h *langHandler) loadProject(file string) {
+	p, err := loadProjectConfig(file)
+	if err != nil {
+		h.logger.Println(err)
+		h.showMessage(LogError, err.Error())
+		return
+	}
+	p.trusted = h.isTrusted(p)
+
+	h.mu.Lock()
+	if h.projects == nil {
+		h.projects = make(map[string]*projectConfig)
+	}
+	if old, ok := h.projects[file]; ok && old.hash == p.hash {
+		p.trusted = p.trusted || old.trusted
+		p.asked = old.asked
+	}
+	h.projects[file] = p
+	ask := !p.trusted && !p.asked && h.conn != nil
+	p.asked = p.asked || ask
+	h.mu.Unlock()
+
+	if ask {
+		go h.askTrust(p)
+	}
+}
+
+// askTrust asks the user whether to run the commands of the project
+// configuration, and lints the documents of the project again once it is
+// trusted.
+func (h *langHandler) askTrust(p *projectConfig) {
+	const trust = "Trust"
+	var item *MessageActionItem
+	err := h.conn.Call(context.Background(), "window/showMessageRequest", &ShowMessageRequestParams{
+		Type:    LogWarning,
+		Message: fmt.Sprintf("%s configures commands to run for the project. Do you trust it?", p.file),
+		Actions: []MessageActionItem{{Title: trust}, {Title: "Ignore"}},
+	}, &item)
+	if err != nil {
+		h.logger.Println(err)
+		return
+	}
+	if item == nil || item.Title != trust {
+		return
+	}
+	if err := h.trust(p); err != nil {
+		h.logger.Println(err)
+		h.showMessage(LogError, err.Error())
+	}
+
+	h.mu.Lock()
+	p.trusted = true
+	var uris []DocumentURI
+	for uri := range h.files {
+		if fname, err := fromURI(uri); err == nil && isInDir(filepath.FromSlash(fname), p.dir) {
+			uris = append(uris, uri)
+		}
+	}
+	h.mu.Unlock()
+	h.lintAgain(uris)
+}
+
+// isTrusted reports whether the user trusted the project configuration with
+// its current contents.
+func (h *langHandler) isTrusted(p *projectConfig) bool {
+	if h.trustFile == "" {
+		return false
+	}
+	f, err := os.Open(h.trustFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if hash, file, ok := strings.Cut(scanner.Text(), " "); ok && hash == p.hash && file == p.file {
+			return true
+		}
+	}
+	return false
+}
+
+// trust remembers that the user trusted the project configuration with its
+// current contents.
+func (h *langHandler) trust(p *projectConfig) error {
+	if h.trustFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.trustFile), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.trustFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %s\n", p.hash, p.file)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// defaultTrustFile returns where the trusted project configurations are
+// remembered.
+func defaultTrustFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "efm-langserver", "trusted-projects")
+}
@@ -0,0 +1,115 @@
+package langserver
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMergeLanguages(t *testing.T) {
+	user := []Language{
+		{Name: "eslint", LintCommand: "eslint"},
+		{LintCommand: "tsc"},
+	}
+	project := []Language{
+		{Name: "eslint", LintCommand: "npx eslint"},
+		{Name: "prettier", FormatCommand: "prettier"},
+	}
+	got := mergeLanguages(user, project)
+	if len(got) != 3 {
+		t.Fatalf("3 tools should be merged but got: %v", got)
+	}
+	if got[0].LintCommand != "npx eslint" {
+		t.Fatalf("the project tool should replace the user tool of the same name but got: %q", got[0].LintCommand)
+	}
+	if got[1].LintCommand != "tsc" || got[2].FormatCommand != "prettier" {
+		t.Fatalf("other tools should be kept and appended but got: %v", got)
+	}
+	if user[0].LintCommand != "eslint" {
+		t.Fatal("the user tools should not be modified")
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "project")
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := "languages:\n  vim:\n    - name: vint\n      lint-command: vint --style\n"
+	if err := os.WriteFile(filepath.Join(root, projectConfigName), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := toURI(filepath.Join(root, "src", "a.vim"))
+
+	newTestHandler := func() *langHandler {
+		return &langHandler{
+			logger:    log.New(io.Discard, "", 0),
+			rootPath:  root,
+			trustFile: filepath.Join(base, "trusted-projects"),
+			configs: map[string][]Language{
+				"vim": {{Name: "vint", LintCommand: "vint"}},
+			},
+		}
+	}
+
+	h := newTestHandler()
+	h.discoverProject(uri)
+	if got := h.languageConfigs(uri, "vim"); len(got) != 1 || got[0].LintCommand != "vint" {
+		t.Fatalf("an untrusted project should not configure tools but got: %v", got)
+	}
+
+	p := h.projects[filepath.Join(root, projectConfigName)]
+	if p == nil {
+		t.Fatal("the project configuration should be discovered")
+	}
+	if err := h.trust(p); err != nil {
+		t.Fatal(err)
+	}
+
+	h = newTestHandler()
+	h.discoverProject(uri)
+	if got := h.languageConfigs(uri, "vim"); len(got) != 1 || got[0].LintCommand != "vint --style" {
+		t.Fatalf("a trusted project should configure tools but got: %v", got)
+	}
+	if got := h.languageConfigs(toURI(filepath.Join(base, "a.vim")), "vim"); got[0].LintCommand != "vint" {
+		t.Fatalf("a project should not configure tools outside of it but got: %v", got)
+	}
+
+	config += "      lint-stdin: true\n"
+	if err := os.WriteFile(filepath.Join(root, projectConfigName), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h.reloadProjects()
+	if got := h.languageConfigs(uri, "vim"); got[0].LintCommand != "vint" {
+		t.Fatalf("a modified project should be trusted again but got: %v", got)
+	}
+
+	// A configuration above the workspace folder is not part of it.
+	h = newTestHandler()
+	h.rootPath = filepath.Join(root, "src")
+	h.discoverProject(uri)
+	if len(h.projects) != 0 {
+		t.Fatalf("a project above the workspace folder should not be discovered but got: %v", h.projects)
+	}
+}
+
+func TestIsInDir(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator)+"tmp", "Project")
+	if !isInDir(filepath.Join(dir, "a.vim"), dir) {
+		t.Fatal("a file in the directory should be in it")
+	}
+	if isInDir(filepath.Join(dir+"2", "a.vim"), dir) {
+		t.Fatal("a file in a sibling directory should not be in it")
+	}
+	other := filepath.Join(string(filepath.Separator)+"tmp", "project", "a.vim")
+	if got := isInDir(other, dir); got != (runtime.GOOS == "windows") {
+		t.Fatalf("case should only be ignored on Windows but got: %v", got)
+	}
+}
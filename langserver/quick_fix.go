@@ -0,0 +1,449 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// quickFix is a fix suggested by a linter, remembered so that
+// textDocument/codeAction can offer it for the diagnostic it belongs to.
+type quickFix struct {
+	Diagnostic Diagnostic
+	Title      string
+	Edits      []TextEdit
+}
+
+// lintFixEdits converts the edits of a lintFix to TextEdits, applying the
+// same offsets as the diagnostic it was reported with. text is the content
+// of the document the edits apply to, if known, and is used to find the end
+// of a line and to convert columns counted in unit to enc.
+func lintFixEdits(text string, fix lintFix, lineOffset, columnOffset int, unit, enc PositionEncodingKind) []TextEdit {
+	lines := strings.Split(text, "\n")
+	position := func(lnum, col int, end bool) Position {
+		line := lnum - 1 - lineOffset
+		if col == 0 {
+			if !end {
+				return Position{Line: line, Character: 0}
+			}
+			if line >= 0 && line < len(lines) {
+				return Position{Line: line, Character: encodedLen(strings.TrimSuffix(lines[line], "\r"), enc)}
+			}
+			return Position{Line: line + 1, Character: 0}
+		}
+		if columnOffset > 0 {
+			col += columnOffset
+		}
+		if text == "" {
+			return Position{Line: line, Character: col - 1}
+		}
+		return Position{Line: line, Character: convertCharacter(lineAt(text, line), col-1, unit, enc)}
+	}
+	edits := make([]TextEdit, 0, len(fix.Edits))
+	for _, e := range fix.Edits {
+		endLnum := e.EndLnum
+		if endLnum == 0 {
+			endLnum = e.Lnum
+		}
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: position(e.Lnum, e.Col, false),
+				End:   position(endLnum, e.EndCol, true),
+			},
+			NewText: e.Text,
+		})
+	}
+	return edits
+}
+
+// sameDiagnostic reports whether a diagnostic sent back by the client in a
+// CodeActionContext is the one published by the server.
+func sameDiagnostic(a, b Diagnostic) bool {
+	if a.Range != b.Range || a.Message != b.Message {
+		return false
+	}
+	if a.Code != nil && b.Code != nil && *a.Code != *b.Code {
+		return false
+	}
+	return true
+}
+
+// codeActionKindAllowed reports whether kind passes the client's
+// CodeActionContext.Only filter, where "source" also allows "source.fixAll".
+func codeActionKindAllowed(only []CodeActionKind, kind CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, o := range only {
+		if kind == o || strings.HasPrefix(string(kind), string(o)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *langHandler) quickFixActions(uri DocumentURI, params *CodeActionParams) []CodeAction {
+	actions := []CodeAction{}
+	if !codeActionKindAllowed(params.Context.Only, QuickFix) {
+		return actions
+	}
+	h.mu.Lock()
+	fixes := h.quickFixes[uri]
+	h.mu.Unlock()
+	for _, diagnostic := range params.Context.Diagnostics {
+		var candidates []quickFix
+		for _, fix := range fixes {
+			if sameDiagnostic(diagnostic, fix.Diagnostic) {
+				candidates = append(candidates, fix)
+			}
+		}
+		for _, fix := range candidates {
+			actions = append(actions, CodeAction{
+				Title:       fix.Title,
+				Kind:        QuickFix,
+				Diagnostics: []Diagnostic{diagnostic},
+				IsPreferred: len(candidates) == 1,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{uri: fix.Edits},
+				},
+			})
+		}
+	}
+	return actions
+}
+
+// fixCommandActions offers the fixes of the lint-fix-command of each tool
+// that reported one of the diagnostics in the context. Clients resolving
+// code actions get them without edits, which are computed by
+// resolveFixAction once one is chosen. For the others, the command is run
+// and every hunk of the unified diff it prints becomes a quick fix for the
+// diagnostics inside that hunk.
+func (h *langHandler) fixCommandActions(ctx context.Context, uri DocumentURI, params *CodeActionParams) []CodeAction {
+	actions := []CodeAction{}
+	wantQuickFix := codeActionKindAllowed(params.Context.Only, QuickFix) && len(params.Context.Diagnostics) > 0
+	wantFixAll := len(params.Context.Only) > 0 && codeActionKindAllowed(params.Context.Only, SourceFixAll)
+	if !wantQuickFix && !wantFixAll {
+		return actions
+	}
+
+	h.mu.Lock()
+	f, ok := h.files[uri]
+	if !ok {
+		h.mu.Unlock()
+		return actions
+	}
+	file := *f
+	configs := h.fixConfigsLocked(uri, file.LanguageID)
+	resolve := h.resolveCodeActions
+	h.mu.Unlock()
+	if len(configs) == 0 {
+		return actions
+	}
+
+	fname, err := fromURI(uri)
+	if err != nil {
+		return actions
+	}
+	fname = filepath.ToSlash(fname)
+
+	for _, config := range configs {
+		name := fixToolName(config)
+		var diagnostics []Diagnostic
+		for _, d := range params.Context.Diagnostics {
+			if config.LintSource == "" || (d.Source != nil && *d.Source == config.LintSource) {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+		if len(diagnostics) == 0 && !wantFixAll {
+			continue
+		}
+		if !fixReadsText(config, fname, file.Text) {
+			continue
+		}
+
+		if resolve {
+			data := &fixActionData{URI: uri, Version: file.Version, Command: config.LintFixCommand}
+			if wantQuickFix && len(diagnostics) > 0 {
+				actions = append(actions, CodeAction{
+					Title:       fmt.Sprintf("Fix with %s", name),
+					Kind:        QuickFix,
+					Diagnostics: diagnostics,
+					Data:        data,
+				})
+			}
+			if wantFixAll {
+				actions = append(actions, CodeAction{
+					Title: fmt.Sprintf("Fix all with %s", name),
+					Kind:  SourceFixAll,
+					Data:  &fixActionData{URI: uri, Version: file.Version, Command: config.LintFixCommand, FixAll: true},
+				})
+			}
+			continue
+		}
+
+		diff, err := h.fixDiff(ctx, uri, file, config, fname)
+		if err != nil {
+			h.logger.Println(err)
+			continue
+		}
+		if diff == nil || len(diff.Hunks) == 0 {
+			continue
+		}
+
+		if wantQuickFix {
+			for _, hunk := range diff.Hunks {
+				fixed := hunkDiagnostics(hunk, diagnostics)
+				if len(fixed) == 0 {
+					continue
+				}
+				actions = append(actions, CodeAction{
+					Title:       fmt.Sprintf("Fix with %s", name),
+					Kind:        QuickFix,
+					Diagnostics: fixed,
+					Edit: &WorkspaceEdit{
+						Changes: map[DocumentURI][]TextEdit{uri: hunkEdits(file.Text, []diffHunk{hunk}, h.encoding())},
+					},
+				})
+			}
+		}
+		if wantFixAll {
+			actions = append(actions, CodeAction{
+				Title: fmt.Sprintf("Fix all with %s", name),
+				Kind:  SourceFixAll,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{uri: hunkEdits(file.Text, diff.Hunks, h.encoding())},
+				},
+			})
+		}
+	}
+	return actions
+}
+
+// fixActionData is the data of the code actions of lint-fix-command left
+// for codeAction/resolve.
+type fixActionData struct {
+	URI     DocumentURI `json:"uri"`
+	Version int         `json:"version"`
+	Command string      `json:"command"`
+	FixAll  bool        `json:"fixAll,omitempty"`
+}
+
+// resolveFixAction sets the edits of action, a code action of
+// lint-fix-command offered for the version of the document in data.
+func (h *langHandler) resolveFixAction(ctx context.Context, action *CodeAction, data *fixActionData) error {
+	h.mu.Lock()
+	f, ok := h.files[data.URI]
+	if !ok {
+		h.mu.Unlock()
+		return fmt.Errorf("document not found: %v", data.URI)
+	}
+	file := *f
+	var config *Language
+	for _, cfg := range h.fixConfigsLocked(data.URI, file.LanguageID) {
+		if cfg.LintFixCommand == data.Command {
+			config = &cfg
+			break
+		}
+	}
+	h.mu.Unlock()
+	if file.Version != data.Version {
+		return &jsonrpc2.Error{Code: codeContentModified, Message: "document has changed since the action was offered"}
+	}
+	if config == nil {
+		return fmt.Errorf("lint-fix-command not found: %v", data.Command)
+	}
+
+	fname, err := fromURI(data.URI)
+	if err != nil {
+		return fmt.Errorf("invalid uri: %v: %v", err, data.URI)
+	}
+	fname = filepath.ToSlash(fname)
+
+	diff, err := h.fixDiff(ctx, data.URI, file, *config, fname)
+	if err != nil {
+		return err
+	}
+	var hunks []diffHunk
+	if diff != nil {
+		for _, hunk := range diff.Hunks {
+			if data.FixAll || len(hunkDiagnostics(hunk, action.Diagnostics)) > 0 {
+				hunks = append(hunks, hunk)
+			}
+		}
+	}
+	action.Edit = &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{data.URI: hunkEdits(file.Text, hunks, h.encoding())},
+	}
+	return nil
+}
+
+// codeContentModified is the LSP error code of requests whose result is no
+// longer valid for the content of the document.
+const codeContentModified = -32801
+
+// fixConfigsLocked returns the tools of the document uri having a
+// lint-fix-command. h.mu must be held.
+func (h *langHandler) fixConfigsLocked(uri DocumentURI, languageID string) []Language {
+	var configs []Language
+	for _, cfg := range h.documentConfigs(uri, languageID) {
+		if strings.TrimSpace(cfg.LintFixCommand) != "" {
+			configs = append(configs, cfg)
+		}
+	}
+	return configs
+}
+
+// fixToolName returns the name of the tool fixing with config, shown in the
+// titles of its actions.
+func fixToolName(config Language) string {
+	if config.LintSource != "" {
+		return config.LintSource
+	}
+	if fields := strings.Fields(config.LintFixCommand); len(fields) > 0 {
+		return fields[0]
+	}
+	return "lint-fix-command"
+}
+
+// hunkDiagnostics returns the diagnostics starting in the lines replaced by
+// hunk.
+func hunkDiagnostics(hunk diffHunk, diagnostics []Diagnostic) []Diagnostic {
+	first, last := hunk.OldStart-1, hunk.OldStart-1+hunk.OldLines
+	var fixed []Diagnostic
+	for _, d := range diagnostics {
+		if d.Range.Start.Line >= first && d.Range.Start.Line < last {
+			fixed = append(fixed, d)
+		}
+	}
+	return fixed
+}
+
+// fixDiffs are the diffs printed by the lint-fix-commands for a version of a
+// document, by command.
+type fixDiffs struct {
+	version int
+	text    string
+	diffs   map[string]*diffFile
+}
+
+// fixDiff returns the diff of fname printed by the lint-fix-command of
+// config for file, which is only run once per version of the document.
+func (h *langHandler) fixDiff(ctx context.Context, uri DocumentURI, file File, config Language, fname string) (*diffFile, error) {
+	h.mu.Lock()
+	cached := h.fixDiffs[uri]
+	if cached != nil && cached.version == file.Version && cached.text == file.Text {
+		if diff, ok := cached.diffs[config.LintFixCommand]; ok {
+			h.mu.Unlock()
+			return diff, nil
+		}
+	}
+	h.mu.Unlock()
+
+	b, err := h.runFixCommand(ctx, config, fname, file.Text)
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseUnifiedDiff(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", config.LintFixCommand, err)
+	}
+	diff := diffFileFor(files, fname)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fixDiffs == nil {
+		h.fixDiffs = make(map[DocumentURI]*fixDiffs)
+	}
+	cached = h.fixDiffs[uri]
+	if cached == nil || cached.version != file.Version || cached.text != file.Text {
+		cached = &fixDiffs{version: file.Version, text: file.Text, diffs: map[string]*diffFile{}}
+		h.fixDiffs[uri] = cached
+	}
+	cached.diffs[config.LintFixCommand] = diff
+	return diff, nil
+}
+
+// fixReadsText reports whether the lint-fix-command of config sees text,
+// the text of the document fname. Commands reading the file itself only do
+// when it is saved, and the hunks they print would otherwise be applied at
+// the wrong lines.
+func fixReadsText(config Language, fname, text string) bool {
+	if config.LintStdin || config.LintTempfile {
+		return true
+	}
+	b, err := os.ReadFile(fname)
+	return err == nil && string(b) == text
+}
+
+// fixCommandLine is lintCommandLine for the lint-fix-command of config.
+func fixCommandLine(config Language, fname, rootPath string) (string, []string) {
+	if len(config.LintFixArgv) > 0 {
+		argv := withInput(config.LintFixArgv, config.LintStdin)
+		if config.LintTempfile {
+			argv = tempfileArgv(config.LintFixArgv)
+		}
+		argv = replaceArgvInputFilename(argv, fname, rootPath)
+		return strings.Join(argv, " "), argv
+	}
+	command := config.LintFixCommand
+	if config.LintTempfile {
+		command = tempfileCommand(command)
+	} else if !config.LintStdin && !strings.Contains(command, "${INPUT}") {
+		command = command + " ${INPUT}"
+	}
+	return replaceCommandInputFilename(command, fname, rootPath), nil
+}
+
+// runFixCommand runs the lint-fix-command of config on text, the text of
+// the document fname, and returns the diff it printed.
+func (h *langHandler) runFixCommand(ctx context.Context, config Language, fname, text string) ([]byte, error) {
+	if !fixReadsText(config, fname, text) {
+		return nil, fmt.Errorf("%s: %v has unsaved changes", config.LintFixCommand, fname)
+	}
+	rootPath := h.findRootPath(fname, config)
+	command, argv := fixCommandLine(config, fname, rootPath)
+
+	c := shellCommand{
+		Command: command,
+		Dir:     rootPath,
+		Env:     append(os.Environ(), config.Env...),
+		Timeout: time.Duration(config.LintTimeout),
+
+		Tool:        config.LintFixCommand,
+		MaxParallel: config.MaxParallel,
+		Priority:    priorityInteractive,
+	}
+	if argv != nil {
+		c.Argv = argv
+	}
+	var b, stderr []byte
+	var err error
+	switch {
+	case config.LintStdin:
+		c.Stdin = strings.NewReader(text)
+		b, stderr, err = h.runCommand(ctx, c)
+	case config.LintTempfile:
+		b, stderr, err = withTempfile(c, fname, text, func(c shellCommand, _ string) ([]byte, []byte, error) {
+			return h.runCommand(ctx, c)
+		})
+	default:
+		b, stderr, err = h.runCommand(ctx, c)
+	}
+	if errors.Is(err, errCommandTimeout) || errors.Is(err, errCommandOutputLimit) {
+		return nil, err
+	}
+	// Diff printing tools usually exit with non-zero when there is a diff.
+	if err != nil && len(b) == 0 {
+		return nil, fmt.Errorf("%s: %v: %s", command, err, string(stderr))
+	}
+	if h.loglevel >= 3 {
+		h.logger.Println(command+":", string(b))
+	}
+	return b, nil
+}
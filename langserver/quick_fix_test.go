@@ -0,0 +1,275 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestCodeActionQuickFix(t *testing.T) {
+	uri := DocumentURI("file:///foo")
+	code := "F841"
+	diagnostic := Diagnostic{
+		Range:   Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 7}},
+		Code:    &code,
+		Message: "unused variable",
+	}
+	h := &langHandler{
+		logger:  log.New(log.Writer(), "", log.LstdFlags),
+		configs: map[string][]Language{},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "python", Text: "def f():\n    foo = 1\n"},
+		},
+		quickFixes: map[DocumentURI][]quickFix{
+			uri: {
+				{
+					Diagnostic: diagnostic,
+					Title:      "Remove assignment to unused variable",
+					Edits:      []TextEdit{{Range: Range{Start: Position{Line: 1, Character: 0}, End: Position{Line: 2, Character: 0}}}},
+				},
+			},
+		},
+	}
+
+	params := &CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Context:      CodeActionContext{Diagnostics: []Diagnostic{diagnostic}},
+	}
+	actions, err := h.codeAction(context.Background(), uri, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 {
+		t.Fatalf("actions should be only one but got: %v", actions)
+	}
+	action, ok := actions[0].(CodeAction)
+	if !ok {
+		t.Fatalf("action should be a CodeAction but got: %T", actions[0])
+	}
+	if action.Kind != QuickFix || !action.IsPreferred {
+		t.Fatalf("action should be a preferred quickfix but got: %+v", action)
+	}
+	if edits := action.Edit.Changes.(map[DocumentURI][]TextEdit)[uri]; len(edits) != 1 {
+		t.Fatalf("edits should be only one but got: %v", edits)
+	}
+
+	params.Context.Only = []CodeActionKind{Refactor}
+	actions, err = h.codeAction(context.Background(), uri, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Fatalf("quick fixes should be filtered out by only but got: %v", actions)
+	}
+}
+
+func TestLintFixEditsEndOfLine(t *testing.T) {
+	text := "aaa\nbbbb\n"
+	edits := lintFixEdits(text, lintFix{Edits: []lintEdit{{Lnum: 2, Col: 2, Text: "x"}}}, 0, 0, PositionEncodingUTF16, PositionEncodingUTF16)
+	if len(edits) != 1 {
+		t.Fatalf("edits should be only one but got: %v", edits)
+	}
+	want := Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 4}}
+	if edits[0].Range != want {
+		t.Fatalf("range should be %v but got: %v", want, edits[0].Range)
+	}
+}
+
+func TestCodeActionFixCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("printf is not available")
+	}
+	base, _ := os.Getwd()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	source := "shellcheck"
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: base,
+		configs: map[string][]Language{
+			"sh": {
+				{
+					LintSource:     source,
+					LintStdin:      true,
+					LintFixCommand: `printf -- '--- a/foo\n+++ b/foo\n@@ -2 +2 @@\n-echo $1\n+echo "$1"\n'`,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "sh", Text: "#!/bin/sh\necho $1\n"},
+		},
+	}
+
+	diagnostic := Diagnostic{
+		Range:   Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 7}},
+		Message: "Double quote to prevent globbing and word splitting.",
+		Source:  &source,
+	}
+	actions := h.fixCommandActions(context.Background(), uri, &CodeActionParams{
+		Context: CodeActionContext{Diagnostics: []Diagnostic{diagnostic}},
+	})
+	if len(actions) != 1 {
+		t.Fatalf("actions should be only one but got: %v", actions)
+	}
+	edits := actions[0].Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+	if got := applyEdits(t, "#!/bin/sh\necho $1\n", edits); got != "#!/bin/sh\necho \"$1\"\n" {
+		t.Fatalf("applying edits should quote $1 but got: %q", got)
+	}
+}
+
+func TestCodeActionFixCommandUnsaved(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("printf is not available")
+	}
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	if err := os.WriteFile(file, []byte("#!/bin/sh\nold\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := toURI(file)
+	// The fix uppercases the second line of the file it is given.
+	fix := Language{
+		LintFixCommand: `printf -- '--- a/foo\n+++ b/foo\n@@ -2 +2 @@\n-x\n+%s\n' "$(sed -n 2p ${INPUT} | tr a-z A-Z)"`,
+	}
+	params := &CodeActionParams{
+		Context: CodeActionContext{Diagnostics: []Diagnostic{{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 4}}, Message: "lowercase"}}},
+	}
+	text := "#!/bin/sh\necho\n"
+
+	tests := []struct {
+		name     string
+		tempfile bool
+		want     string
+	}{
+		{"reading the file", false, ""},
+		{"lint-tempfile", true, "#!/bin/sh\nECHO\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fix
+			config.LintTempfile = test.tempfile
+			h := &langHandler{
+				logger:   log.New(io.Discard, "", 0),
+				rootPath: base,
+				configs:  map[string][]Language{"sh": {config}},
+				files: map[DocumentURI]*File{
+					uri: {LanguageID: "sh", Text: text},
+				},
+			}
+			actions := h.fixCommandActions(context.Background(), uri, params)
+			if test.want == "" {
+				if len(actions) != 0 {
+					t.Fatalf("a fix reading the saved file should not be offered for unsaved changes but got: %v", actions)
+				}
+				return
+			}
+			if len(actions) != 1 {
+				t.Fatalf("actions should be only one but got: %v", actions)
+			}
+			edits := actions[0].Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+			if got := applyEdits(t, text, edits); got != test.want {
+				t.Fatalf("the fix should be computed for the unsaved text but got: %q", got)
+			}
+		})
+	}
+}
+
+func TestCodeActionFixCommandResolve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("printf is not available")
+	}
+	base := t.TempDir()
+	file := filepath.Join(base, "foo")
+	uri := toURI(file)
+	source := "shellcheck"
+	runs := filepath.Join(base, "runs")
+
+	h := &langHandler{
+		logger:             log.New(io.Discard, "", 0),
+		rootPath:           base,
+		resolveCodeActions: true,
+		configs: map[string][]Language{
+			"sh": {
+				{
+					LintSource:     source,
+					LintStdin:      true,
+					LintFixCommand: `echo >> ` + runs + `; printf -- '--- a/foo\n+++ b/foo\n@@ -2 +2 @@\n-echo $1\n+echo "$1"\n'`,
+				},
+				{LintFixCommand: "  "},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {LanguageID: "sh", Text: "#!/bin/sh\necho $1\n", Version: 1},
+		},
+	}
+	countRuns := func() int {
+		b, _ := os.ReadFile(runs)
+		return len(b)
+	}
+
+	diagnostic := Diagnostic{
+		Range:   Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 7}},
+		Message: "Double quote to prevent globbing and word splitting.",
+		Source:  &source,
+	}
+	params := &CodeActionParams{
+		Context: CodeActionContext{Diagnostics: []Diagnostic{diagnostic}},
+	}
+	actions := h.fixCommandActions(context.Background(), uri, params)
+	if len(actions) != 1 {
+		t.Fatalf("actions should be only one but got: %v", actions)
+	}
+	if actions[0].Edit != nil || countRuns() != 0 {
+		t.Fatal("lint-fix-command should not run before the action is resolved")
+	}
+
+	resolveWith := func(ctx context.Context, action CodeAction) (CodeAction, error) {
+		b, err := json.Marshal(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := json.RawMessage(b)
+		result, err := h.handleCodeActionResolve(ctx, nil, &jsonrpc2.Request{Params: &raw})
+		if err != nil {
+			return CodeAction{}, err
+		}
+		return result.(CodeAction), nil
+	}
+	resolve := func(action CodeAction) (CodeAction, error) {
+		return resolveWith(context.Background(), action)
+	}
+
+	// A cancelled request does not run the command.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := resolveWith(ctx, actions[0]); err == nil || countRuns() != 0 {
+		t.Fatal("resolving with a cancelled request should fail without running lint-fix-command")
+	}
+
+	for i := 0; i < 2; i++ {
+		resolved, err := resolve(actions[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		edits := resolved.Edit.Changes.(map[DocumentURI][]TextEdit)[uri]
+		if got := applyEdits(t, "#!/bin/sh\necho $1\n", edits); got != "#!/bin/sh\necho \"$1\"\n" {
+			t.Fatalf("applying edits should quote $1 but got: %q", got)
+		}
+	}
+	if got := countRuns(); got != 1 {
+		t.Fatalf("lint-fix-command should run once for the version but ran %d times", got)
+	}
+
+	h.files[uri].Version = 2
+	if _, err := resolve(actions[0]); err == nil {
+		t.Fatal("resolving an action of an older version should fail")
+	}
+}
@@ -0,0 +1,234 @@
+package langserver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaValidator validates YAML documents against the subset of JSON schema
+// used by schema.json.
+type schemaValidator struct {
+	root     map[string]any
+	problems []string
+	// reported are the nodes with problems, which are reported once even
+	// when merged into several mappings.
+	reported map[*yaml.Node]bool
+}
+
+// validateSchema validates the YAML document node against the JSON schema,
+// and returns the problems found, prefixed with their line and column.
+func validateSchema(schema map[string]any, node *yaml.Node) []string {
+	v := &schemaValidator{root: schema, reported: make(map[*yaml.Node]bool)}
+	v.validate(schema, node, "")
+	return v.problems
+}
+
+func (v *schemaValidator) report(node *yaml.Node, path, format string, args ...any) {
+	if v.reported[node] {
+		return
+	}
+	v.reported[node] = true
+	if path != "" {
+		format = path + ": " + format
+	}
+	v.problems = append(v.problems, fmt.Sprintf("%d:%d: ", node.Line, node.Column)+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(schema map[string]any, node *yaml.Node, path string) {
+	for node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		definitions, _ := v.root["definitions"].(map[string]any)
+		if def, ok := definitions[name].(map[string]any); ok {
+			v.validate(def, node, path)
+		}
+		return
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var types []string
+		matched := false
+		for _, s := range anyOf {
+			s, _ := s.(map[string]any)
+			sub := &schemaValidator{root: v.root, reported: make(map[*yaml.Node]bool)}
+			sub.validate(s, node, path)
+			if len(sub.problems) == 0 {
+				matched = true
+				break
+			}
+			if typ, ok := s["type"].(string); ok {
+				types = append(types, typ)
+			}
+		}
+		if !matched {
+			v.report(node, path, "must be %s", strings.Join(types, " or "))
+			return
+		}
+	}
+	if typ, ok := schema["type"].(string); ok && !hasSchemaType(node, typ) {
+		v.report(node, path, "must be %s but is %s", typ, schemaType(node))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		var values []string
+		found := false
+		for _, e := range enum {
+			values = append(values, fmt.Sprint(e))
+			if fmt.Sprint(e) == node.Value {
+				found = true
+			}
+		}
+		if !found {
+			v.report(node, path, "%q is not one of %s", node.Value, strings.Join(values, ", "))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok && node.Kind == yaml.ScalarNode {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(node.Value) {
+			v.report(node, path, "%q is not valid", node.Value)
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok && node.Kind == yaml.ScalarNode {
+		if f, err := strconv.ParseFloat(node.Value, 64); err == nil && f < minimum {
+			v.report(node, path, "must be at least %v", minimum)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		v.validateMapping(schema, node, path)
+	case yaml.SequenceNode:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range node.Content {
+				v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateMapping(schema map[string]any, node *yaml.Node, path string) {
+	properties, _ := schema["properties"].(map[string]any)
+	patternProperties, _ := schema["patternProperties"].(map[string]any)
+	patterns := make([]string, 0, len(patternProperties))
+	for pattern := range patternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	keys := make(map[string]bool)
+	for _, pair := range mappingPairs(node) {
+		key, value := pair[0], pair[1]
+		keys[key.Value] = true
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+		matched := false
+		if s, ok := properties[key.Value].(map[string]any); ok {
+			v.validate(s, value, keyPath)
+			matched = true
+		}
+		for _, pattern := range patterns {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(key.Value) {
+				s, _ := patternProperties[pattern].(map[string]any)
+				v.validate(s, value, keyPath)
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.report(key, path, "unknown key %q", key.Value)
+			}
+		case map[string]any:
+			v.validate(additional, value, keyPath)
+		}
+	}
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name := fmt.Sprint(r); !keys[name] {
+				v.report(node, path, "%q is required", name)
+			}
+		}
+	}
+}
+
+// mappingPairs returns the keys and values of the mapping node, followed by
+// those merged into it with `<<` which it does not override.
+func mappingPairs(node *yaml.Node) [][2]*yaml.Node {
+	var pairs, merged [][2]*yaml.Node
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind == yaml.ScalarNode && key.ShortTag() == "!!merge" {
+			for value.Kind == yaml.AliasNode {
+				value = value.Alias
+			}
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+			for _, source := range sources {
+				for source.Kind == yaml.AliasNode {
+					source = source.Alias
+				}
+				merged = append(merged, mappingPairs(source)...)
+			}
+			continue
+		}
+		seen[key.Value] = true
+		pairs = append(pairs, [2]*yaml.Node{key, value})
+	}
+	for _, pair := range merged {
+		if !seen[pair[0].Value] {
+			seen[pair[0].Value] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// schemaType returns the JSON schema type of node.
+func schemaType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// hasSchemaType reports whether node is of the JSON schema type typ. Any
+// scalar but null is a string, as it is decoded into one.
+func hasSchemaType(node *yaml.Node, typ string) bool {
+	got := schemaType(node)
+	switch typ {
+	case "number":
+		return got == "number" || got == "integer"
+	case "string":
+		return node.Kind == yaml.ScalarNode && got != "null"
+	}
+	return got == typ
+}
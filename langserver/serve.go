@@ -0,0 +1,23 @@
+package langserver
+
+import (
+	"context"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Serve serves a single client on stream until it disconnects or ctx is
+// done. Each call gets its own handler state, so one Config can be shared by
+// many clients as long as it is not modified.
+func Serve(ctx context.Context, config *Config, stream jsonrpc2.ObjectStream, opts ...jsonrpc2.ConnOpt) {
+	h := newHandler(config)
+	conn := jsonrpc2.NewConn(ctx, stream, h.jsonrpcHandler(), opts...)
+	select {
+	case <-conn.DisconnectNotify():
+	case <-ctx.Done():
+		conn.Close()
+		<-conn.DisconnectNotify()
+	}
+	// Stop linting for a client that went away without a shutdown request.
+	h.shutdown()
+}
@@ -0,0 +1,224 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestServeMultipleClients(t *testing.T) {
+	config := &Config{
+		Logger:      log.New(log.Writer(), "", log.LstdFlags),
+		Commands:    &[]Command{},
+		RootMarkers: &[]string{},
+		Languages: &map[string][]Language{
+			"vim": {{HoverCommand: "echo"}},
+		},
+	}
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	var clients []*jsonrpc2.Conn
+	for i := 0; i < 2; i++ {
+		server, client := net.Pipe()
+		go func() {
+			Serve(ctx, config, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}))
+			done <- struct{}{}
+		}()
+		clients = append(clients, jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+			return nil, nil
+		})))
+	}
+
+	for _, client := range clients {
+		var result InitializeResult
+		if err := client.Call(ctx, "initialize", InitializeParams{}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Capabilities.HoverProvider {
+			t.Fatal("every client should get the capabilities of the shared config")
+		}
+	}
+
+	// Closing one client must not affect the other.
+	clients[0].Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve should return when its client disconnects")
+	}
+	var result InitializeResult
+	if err := clients[1].Call(ctx, "initialize", InitializeParams{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	clients[1].Close()
+	<-done
+}
+
+func TestServeWorkspaceDiagnosticHeld(t *testing.T) {
+	config := &Config{
+		Logger:      log.New(log.Writer(), "", log.LstdFlags),
+		Commands:    &[]Command{},
+		RootMarkers: &[]string{},
+		Languages:   &map[string][]Language{},
+	}
+
+	ctx := context.Background()
+	server, client := net.Pipe()
+	go Serve(ctx, config, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}))
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+
+	id := jsonrpc2.ID{Num: 100}
+	errs := make(chan error)
+	go func() {
+		var report WorkspaceDiagnosticReport
+		errs <- conn.Call(ctx, "workspace/diagnostic", WorkspaceDiagnosticParams{
+			PreviousResultIDs: []PreviousResultID{{URI: "file:///foo", Value: "1"}},
+		}, &report, jsonrpc2.PickID(id))
+	}()
+
+	// The held request must not keep the others waiting.
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("workspace/diagnostic should be held until something changes but got: %v", err)
+	default:
+	}
+
+	// The request may not have been read by the server yet, so cancelling
+	// is tried again until it is answered.
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := conn.Notify(ctx, "$/cancelRequest", CancelParams{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-errs:
+			var e *jsonrpc2.Error
+			if !errors.As(err, &e) || e.Code != codeRequestCancelled {
+				t.Fatalf("workspace/diagnostic should be cancelled but got: %v", err)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("workspace/diagnostic should be answered once cancelled")
+		}
+	}
+}
+
+func TestServeDocumentDiagnosticInBackground(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	base := t.TempDir()
+	started := filepath.Join(base, "started")
+	config := &Config{
+		Logger:      log.New(log.Writer(), "", log.LstdFlags),
+		Commands:    &[]Command{},
+		RootMarkers: &[]string{},
+		Languages: &map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `echo >> ` + started + `; sleep 10`,
+					LintAfterOpen:      true,
+					LintIgnoreExitCode: true,
+					LintStdin:          true,
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	server, client := net.Pipe()
+	go Serve(ctx, config, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}))
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) {
+		return nil, nil
+	}))
+	defer conn.Close()
+
+	var result InitializeResult
+	if err := conn.Call(ctx, "initialize", InitializeParams{
+		RootURI: toURI(base),
+		Capabilities: ClientCapabilities{
+			TextDocument: &TextDocumentClientCapabilities{Diagnostic: &DiagnosticClientCapabilities{}},
+		},
+	}, &result); err != nil {
+		t.Fatal(err)
+	}
+	uri := toURI(filepath.Join(base, "foo.vim"))
+	if err := conn.Notify(ctx, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "vim", Version: 1, Text: "a\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pull := func(id jsonrpc2.ID) chan error {
+		errs := make(chan error, 1)
+		go func() {
+			var report DocumentDiagnosticReport
+			errs <- conn.Call(ctx, "textDocument/diagnostic", DocumentDiagnosticParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+			}, &report, jsonrpc2.PickID(id))
+		}()
+		return errs
+	}
+	runs := func() int {
+		b, _ := os.ReadFile(started)
+		return len(b)
+	}
+	waitFor := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for runs() < n {
+			if time.Now().After(deadline) {
+				t.Fatal("the linter should be started")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	wantCode := func(errs chan error, code int64) {
+		t.Helper()
+		select {
+		case err := <-errs:
+			var e *jsonrpc2.Error
+			if !errors.As(err, &e) || e.Code != code {
+				t.Fatalf("textDocument/diagnostic should fail with %d but got: %v", code, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("textDocument/diagnostic should be answered without waiting for the linter")
+		}
+	}
+
+	// An edit while linting is handled at once, and stops the linter.
+	errs := pull(jsonrpc2.ID{Num: 100})
+	waitFor(1)
+	if err := conn.Notify(ctx, "textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{TextDocumentIdentifier: TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "b\n"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wantCode(errs, codeServerCancelled)
+
+	id := jsonrpc2.ID{Num: 101}
+	errs = pull(id)
+	waitFor(2)
+	if err := conn.Notify(ctx, "$/cancelRequest", CancelParams{ID: id}); err != nil {
+		t.Fatal(err)
+	}
+	wantCode(errs, codeRequestCancelled)
+}
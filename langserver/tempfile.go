@@ -0,0 +1,83 @@
+package langserver
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempfilePrefix starts the names of the temporary files of withTempfile.
+const tempfilePrefix = "efm-langserver-"
+
+// isTempfile reports whether fname is a temporary file of withTempfile,
+// named tempfilePrefix, random digits, "-" and the name of the document.
+func isTempfile(fname string) bool {
+	rest, ok := strings.CutPrefix(filepath.Base(fname), tempfilePrefix)
+	if !ok {
+		return false
+	}
+	digits := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	return digits > 0 && rest[digits] == '-'
+}
+
+// tempfileCommand makes command take the temporary file written by
+// withTempfile in place of the document: ${TEMPFILE} when given, or else
+// ${INPUT}, which is appended when missing.
+func tempfileCommand(command string) string {
+	if strings.Contains(command, "${TEMPFILE}") {
+		return command
+	}
+	if strings.Contains(command, "${INPUT}") {
+		return strings.Replace(command, "${INPUT}", "${TEMPFILE}", -1)
+	}
+	return command + " ${TEMPFILE}"
+}
+
+// tempfileArgv is tempfileCommand for argv lists.
+func tempfileArgv(argv []string) []string {
+	for _, arg := range argv {
+		if strings.Contains(arg, "${TEMPFILE}") {
+			return argv
+		}
+	}
+	for _, arg := range argv {
+		if strings.Contains(arg, "${INPUT}") {
+			return replaceArgv(argv, "${INPUT}", "${TEMPFILE}")
+		}
+	}
+	return append(append([]string{}, argv...), "${TEMPFILE}")
+}
+
+// withTempfile writes text to a temporary file beside fname, so that tools
+// find the same configuration files and see the same extension, and calls
+// run with c whose ${TEMPFILE} is replaced by its path tmp. The temporary
+// file is named as fname in the output, and removed afterwards.
+func withTempfile(c shellCommand, fname, text string, run func(c shellCommand, tmp string) ([]byte, []byte, error)) ([]byte, []byte, error) {
+	base := filepath.Base(fname)
+	f, err := os.CreateTemp(filepath.Dir(fname), tempfilePrefix+"*-"+base)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Like ${INPUT}, which is given with slashes.
+	tmp := filepath.ToSlash(f.Name())
+	c.Command = strings.Replace(c.Command, "${TEMPFILE}", escapeBrackets(tmp), -1)
+	if len(c.Argv) > 0 {
+		c.Argv = replaceArgv(c.Argv, "${TEMPFILE}", tmp)
+	}
+
+	stdout, stderr, err := run(c, f.Name())
+	// Tools may print the path as given, as an absolute path, or relative to
+	// the directory they run in; the random name is in all of them.
+	old, new := []byte(filepath.Base(tmp)), []byte(base)
+	return bytes.ReplaceAll(stdout, old, new), bytes.ReplaceAll(stderr, old, new), err
+}
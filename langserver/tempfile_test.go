@@ -0,0 +1,91 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLintTempfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses grep")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "foo.vim")
+	if err := os.WriteFile(file, []byte("good\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: dir,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					LintCommand:        `grep -Hn bad`,
+					LintIgnoreExitCode: true,
+					LintTempfile:       true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "good\nbad\n",
+			},
+		},
+	}
+
+	d, err := h.lint(context.Background(), uri, eventTypeChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 1 || len(d[uri]) != 1 || d[uri][0].Range.Start.Line != 1 {
+		t.Fatalf("the unsaved text should be linted as the document but got: %v", d)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("the temporary file should be removed but got: %v", entries)
+	}
+}
+
+func TestFormattingTempfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sed")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "foo.vim")
+	uri := toURI(file)
+
+	h := &langHandler{
+		logger:   log.New(log.Writer(), "", log.LstdFlags),
+		rootPath: dir,
+		configs: map[string][]Language{
+			"vim": {
+				{
+					FormatCommand:  `sed s/a/b/ ${TEMPFILE} ${--tab-width:tabSize}`,
+					FormatTempfile: true,
+				},
+			},
+		},
+		files: map[DocumentURI]*File{
+			uri: {
+				LanguageID: "vim",
+				Text:       "a\n",
+			},
+		},
+	}
+
+	rng := Range{Position{-1, -1}, Position{-1, -1}}
+	edits, err := h.rangeFormatRequest(uri, rng, FormattingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := applyTextEdits("a\n", edits, PositionEncodingUTF16); got != "b\n" {
+		t.Fatalf("the unsaved text should be formatted but got: %q", got)
+	}
+}
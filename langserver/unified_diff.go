@@ -0,0 +1,175 @@
+package langserver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diffFile is the part of a unified diff that applies to a single file.
+type diffFile struct {
+	OldName string
+	NewName string
+	Hunks   []diffHunk
+}
+
+// diffHunk is a single "@@ -l,s +l,s @@" section. Lines keep their ' ', '-'
+// or '+' prefix and their trailing newline, which is dropped for a line
+// followed by "\ No newline at end of file".
+type diffHunk struct {
+	OldStart int
+	OldLines int
+	Lines    []string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff parses the output of `diff -u`, `git diff` and the `--diff`
+// modes of formatters and fixers.
+func parseUnifiedDiff(b []byte) ([]diffFile, error) {
+	var files []diffFile
+	var file *diffFile
+	var hunk *diffHunk
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "--- ") && (hunk == nil || hunkDone(hunk)):
+			files = append(files, diffFile{OldName: diffFileName(line[4:])})
+			file = &files[len(files)-1]
+			hunk = nil
+		case strings.HasPrefix(line, "+++ ") && file != nil && hunk == nil:
+			file.NewName = diffFileName(line[4:])
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header: %v", line)
+			}
+			if file == nil {
+				files = append(files, diffFile{})
+				file = &files[len(files)-1]
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			oldLines := 1
+			if m[2] != "" {
+				oldLines, _ = strconv.Atoi(m[2])
+			}
+			file.Hunks = append(file.Hunks, diffHunk{OldStart: oldStart, OldLines: oldLines})
+			hunk = &file.Hunks[len(file.Hunks)-1]
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1] = strings.TrimSuffix(hunk.Lines[n-1], "\n")
+			}
+		case hunk != nil && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			hunk.Lines = append(hunk.Lines, line+"\n")
+		case hunk != nil && line == "":
+			// Some tools strip the trailing space of empty context lines.
+			hunk.Lines = append(hunk.Lines, " \n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// hunkDone reports whether all old lines of the hunk have been read, so a
+// following "--- " line starts a new file rather than deleting a line
+// starting with "-- ".
+func hunkDone(hunk *diffHunk) bool {
+	old := 0
+	for _, line := range hunk.Lines {
+		if line[0] != '+' {
+			old++
+		}
+	}
+	return old >= hunk.OldLines
+}
+
+func diffFileName(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// diffFileFor picks the diff for fname out of files. Diffs of a single file
+// are assumed to be for fname since tools reading stdin name it arbitrarily.
+func diffFileFor(files []diffFile, fname string) *diffFile {
+	if len(files) == 1 {
+		return &files[0]
+	}
+	for i, file := range files {
+		for _, name := range []string{file.NewName, file.OldName} {
+			if name != "" && name != "/dev/null" && (name == fname || strings.HasSuffix(fname, "/"+name)) {
+				return &files[i]
+			}
+		}
+	}
+	return nil
+}
+
+// hunkEdits converts the changed lines of hunks into TextEdits against text,
+// leaving the context lines untouched.
+func hunkEdits(text string, hunks []diffHunk, enc PositionEncodingKind) []TextEdit {
+	var edits []TextEdit
+	for _, hunk := range hunks {
+		line := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			// An empty old range is reported at the line before the insertion.
+			line = hunk.OldStart
+		}
+		for i := 0; i < len(hunk.Lines); {
+			if hunk.Lines[i][0] == ' ' {
+				line++
+				i++
+				continue
+			}
+			start := line
+			var newText strings.Builder
+			for ; i < len(hunk.Lines) && hunk.Lines[i][0] != ' '; i++ {
+				if hunk.Lines[i][0] == '-' {
+					line++
+				} else {
+					newText.WriteString(hunk.Lines[i][1:])
+				}
+			}
+			edits = append(edits, TextEdit{
+				Range: Range{
+					Start: Position{Line: start, Character: 0},
+					End:   Position{Line: line, Character: 0},
+				},
+				NewText: newText.String(),
+			})
+		}
+	}
+	return clampEdits(text, edits, enc)
+}
+
+// clampEdits moves positions past the last line of a document without a
+// trailing newline to the end of that line, where clients can apply them.
+func clampEdits(text string, edits []TextEdit, enc PositionEncodingKind) []TextEdit {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return edits
+	}
+	lines := splitLines(text)
+	lastLine := len(lines) - 1
+	lastChar := encodedLen(lines[lastLine], enc)
+	for i := range edits {
+		if edits[i].Range.Start.Line > lastLine {
+			edits[i].Range.Start = Position{Line: lastLine, Character: lastChar}
+		}
+		if edits[i].Range.End.Line > lastLine {
+			edits[i].Range.End = Position{Line: lastLine, Character: lastChar}
+		}
+	}
+	return edits
+}
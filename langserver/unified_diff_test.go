@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `--- a/main.sh
++++ b/main.sh
+@@ -1,3 +1,3 @@
+ #!/bin/sh
+-echo $1
++echo "$1"
+ exit 0
+@@ -6,2 +6,3 @@
+ foo
+ bar
++baz
+`
+	files, err := parseUnifiedDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("files should be only one but got: %v", files)
+	}
+	if files[0].OldName != "main.sh" || files[0].NewName != "main.sh" {
+		t.Fatalf("file names should be main.sh but got: %q %q", files[0].OldName, files[0].NewName)
+	}
+	if len(files[0].Hunks) != 2 {
+		t.Fatalf("hunks should be two but got: %v", files[0].Hunks)
+	}
+
+	before := "#!/bin/sh\necho $1\nexit 0\n\n\nfoo\nbar\n"
+	after := "#!/bin/sh\necho \"$1\"\nexit 0\n\n\nfoo\nbar\nbaz\n"
+	if got := applyEdits(t, before, hunkEdits(before, files[0].Hunks, PositionEncodingUTF16)); got != after {
+		t.Fatalf("applying edits should produce %q but got: %q", after, got)
+	}
+}
+
+func TestParseUnifiedDiffNoNewlineAtEOF(t *testing.T) {
+	diff := `--- main.sh
++++ main.sh
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+`
+	files, err := parseUnifiedDiff([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := "a\nb"
+	after := "a\nc\n"
+	if got := applyEdits(t, before, hunkEdits(before, files[0].Hunks, PositionEncodingUTF16)); got != after {
+		t.Fatalf("applying edits should produce %q but got: %q", after, got)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"strings"
 )
 
-func convertRowColToIndex(s string, row, col int) int {
+// convertRowColToIndex returns the offset of row and col, counting col in
+// code units of enc, as a count of code units of unit from the start of s.
+func convertRowColToIndex(s string, row, col int, enc, unit PositionEncodingKind) int {
 	lines := strings.Split(s, "\n")
 
 	if row < 0 {
@@ -15,16 +17,67 @@ func convertRowColToIndex(s string, row, col int) int {
 
 	if col < 0 {
 		col = 0
-	} else if col > len(lines[row]) {
-		col = len(lines[row])
 	}
+	col = encodedLen(lines[row][:characterOffset(lines[row], col, enc)], unit)
 
 	index := 0
 	for i := 0; i < row; i++ {
 		// Add the length of each line plus 1 for the newline character
-		index += len(lines[i]) + 1
+		index += encodedLen(lines[i], unit) + 1
 	}
 	index += col
 
 	return index
 }
+
+// positionToOffset returns the byte offset of pos in s, where pos.Character
+// counts code units of enc. Lines may end with "\n", "\r\n" or "\r". A
+// character past the end of its line means the end of that line, and a line
+// past the end of s means the end of s.
+func positionToOffset(s string, pos Position, enc PositionEncodingKind) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexAny(s[offset:], "\r\n")
+		if i < 0 {
+			return len(s)
+		}
+		offset += i + 1
+		if s[offset-1] == '\r' && offset < len(s) && s[offset] == '\n' {
+			offset++
+		}
+	}
+	line := s[offset:]
+	if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+		line = line[:i]
+	}
+	return offset + characterOffset(line, pos.Character, enc)
+}
+
+// offsetToPosition returns the position of the byte offset in s, counting
+// characters in code units of enc. It is the inverse of positionToOffset.
+func offsetToPosition(s string, offset int, enc PositionEncodingKind) Position {
+	if offset > len(s) {
+		offset = len(s)
+	}
+	line, start := 0, 0
+	for i := 0; i < offset; i++ {
+		if s[i] == '\n' || (s[i] == '\r' && (i+1 >= len(s) || s[i+1] != '\n')) {
+			line++
+			start = i + 1
+		}
+	}
+	return Position{Line: line, Character: encodedLen(s[start:offset], enc)}
+}
+
+// applyContentChange applies a change sent with textDocument/didChange.
+func applyContentChange(text string, change TextDocumentContentChangeEvent, enc PositionEncodingKind) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	start := positionToOffset(text, change.Range.Start, enc)
+	end := positionToOffset(text, change.Range.End, enc)
+	if end < start {
+		start, end = end, start
+	}
+	return text[:start] + change.Text + text[end:]
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/mattn/efm-langserver/langserver"
+)
+
+// listen opens a listener for addr, which is either tcp://host:port or
+// unix:///path/to/socket.
+func listen(addr string) (net.Listener, error) {
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid listen address: %v", addr)
+	}
+	switch scheme {
+	case "tcp":
+		return net.Listen("tcp", rest)
+	case "unix":
+		l, err := net.Listen("unix", rest)
+		if err == nil {
+			return l, nil
+		}
+		// A socket left behind by a server that did not exit cleanly can be
+		// replaced, as long as nobody is listening on it anymore.
+		if fi, serr := os.Lstat(rest); serr != nil || fi.Mode()&os.ModeSocket == 0 {
+			return nil, err
+		}
+		if c, derr := net.Dial("unix", rest); derr == nil {
+			c.Close()
+			return nil, err
+		}
+		if err := os.Remove(rest); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", rest)
+	}
+	return nil, fmt.Errorf("invalid listen address: %v: scheme must be tcp or unix", addr)
+}
+
+// serveListener accepts clients on l until interrupted. Every connection
+// gets its own handler state and a logger prefixed with its ID, and shares
+// config with the others.
+func serveListener(l net.Listener, config *langserver.Config, traceMessages, quiet bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	logger := config.Logger
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	// Hide the *os.File behind the logger so a client changing its logfile
+	// does not close the one shared by all connections.
+	out := struct{ io.Writer }{logger.Writer()}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for id := 1; ; id++ {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		log.Printf("efm-langserver: connection %d from %v", id, conn.RemoteAddr())
+
+		connConfig := *config
+		connConfig.Logger = log.New(out, fmt.Sprintf("[%d] ", id), logger.Flags())
+		var connOpt []jsonrpc2.ConnOpt
+		if traceMessages {
+			connOpt = append(connOpt, jsonrpc2.LogMessages(connConfig.Logger))
+		} else if quiet {
+			connOpt = append(connOpt, jsonrpc2.LogMessages(log.New(io.Discard, "", 0)))
+		}
+
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			langserver.Serve(ctx, &connConfig, jsonrpc2.NewBufferedStream(conn, jsonrpc2.VSCodeObjectCodec{}), connOpt...)
+			log.Printf("efm-langserver: connection %d closed", id)
+		}(id)
+	}
+}
@@ -30,6 +30,7 @@ func main() {
 	var dump bool
 	var showVersion bool
 	var quiet bool
+	var listenAddr string
 
 	flag.StringVar(&yamlfile, "c", "", "path to config.yaml")
 	flag.StringVar(&logfile, "logfile", "", "logfile")
@@ -37,6 +38,7 @@ func main() {
 	flag.BoolVar(&dump, "d", false, "dump configuration")
 	flag.BoolVar(&showVersion, "v", false, "Print the version")
 	flag.BoolVar(&quiet, "q", false, "Run quieter")
+	flag.StringVar(&listenAddr, "listen", "", "listen on tcp://host:port or unix:///path instead of stdio")
 	flag.Parse()
 
 	if showVersion {
@@ -68,6 +70,11 @@ func main() {
 		}
 	}
 
+	if flag.Arg(0) == "check" || flag.Arg(0) == "doctor" {
+		// Problems with the configuration are reported instead of failing.
+		os.Exit(runCheck(yamlfile, flag.Args()[1:], os.Stdout))
+	}
+
 	config, err := langserver.LoadConfig(yamlfile)
 	if err != nil {
 		log.Fatal(err)
@@ -81,7 +88,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	if flag.NArg() != 0 {
+	if flag.NArg() != 0 && flag.Arg(0) != "lint" && flag.Arg(0) != "format" && flag.Arg(0) != "preset" && flag.Arg(0) != "explain" {
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -90,8 +97,6 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
-	log.Println("efm-langserver: reading on stdin, writing on stdout")
-
 	if logfile == "" {
 		logfile = config.LogFile
 	}
@@ -127,6 +132,30 @@ func main() {
 		connOpt = append(connOpt, jsonrpc2.LogMessages(log.New(io.Discard, "", 0)))
 	}
 
+	if flag.NArg() != 0 {
+		// Only log to stderr when asked for, to keep the output of commands
+		// clean.
+		if config.Logger == nil && !loglevelSet {
+			config.Logger = log.New(io.Discard, "", 0)
+		}
+		os.Exit(runCommand(config, flag.Args()))
+	}
+
+	if listenAddr != "" {
+		l, err := listen(listenAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("efm-langserver: listening on %v", l.Addr())
+		if err := serveListener(l, config, logfile != "" && loglevel >= 5, quiet); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("efm-langserver: connections closed")
+		return
+	}
+
+	log.Println("efm-langserver: reading on stdin, writing on stdout")
+
 	handler := langserver.NewHandler(config)
 	<-jsonrpc2.NewConn(
 		context.Background(),